@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"example.com/cache"
+	"example.com/config"
+	"example.com/database"
+	"example.com/health"
+	"example.com/mail"
+	"example.com/migrations"
+	"example.com/models"
+	"example.com/routes"
+	"example.com/server"
+	logger "example.com/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// App owns the lifecycle of the application's long-lived dependencies
+type App struct {
+	config *config.Config
+	logger *logger.Logger
+	db     *database.DbInstance
+	cache  cache.Cache
+	health *health.Registry
+	tokens *logger.TokenService
+	mailer mail.Mailer
+	tasks  *logger.Tasks
+	router *gin.Engine
+	server *server.Server
+}
+
+// NewApp initializes all dependencies and builds the router and server
+func NewApp(cfg *config.Config) (*App, error) {
+	gin.SetMode(cfg.Server.Mode)
+
+	// Initialize logger
+	appLogger, err := logger.NewLogger(cfg.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	db, err := database.Connect(context.Background(), cfg.Database, appLogger)
+	if err != nil {
+		appLogger.Close()
+		return nil, err
+	}
+
+	if cfg.Database.MigrateOnStart {
+		if err := migrate(context.Background(), db, appLogger); err != nil {
+			db.Close()
+			appLogger.Close()
+			return nil, err
+		}
+	}
+
+	tokens, err := logger.NewTokenService(cfg.JWT, database.NewRefreshTokenStore(db))
+	if err != nil {
+		db.Close()
+		appLogger.Close()
+		return nil, err
+	}
+	tokens.SetClaimsLoader(userClaimsLoader(database.NewRepository[models.User](db)))
+
+	app := &App{
+		config: cfg,
+		logger: appLogger,
+		db:     db,
+		cache:  cache.NewMemory(),
+		health: health.NewRegistry(cfg.Server.HealthTimeout),
+		tokens: tokens,
+		mailer: mail.New(cfg.Email, cfg.Server.Mode, appLogger),
+		tasks:  logger.NewTasks(),
+	}
+	app.registerHealthChecks()
+
+	app.router = routes.NewRouter(cfg, routes.Dependencies{
+		Logger: app.logger,
+		DB:     app.db,
+		Cache:  app.cache,
+		Health: app.health,
+		Tokens: app.tokens,
+		Mailer: app.mailer,
+		Tasks:  app.tasks,
+	})
+	app.server, err = server.New(cfg.Server, app.router, app.logger)
+	if err != nil {
+		app.Close()
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// registerHealthChecks registers a readiness check for every configured dependency
+func (a *App) registerHealthChecks() {
+	a.health.Register("database", health.DatabaseCheck(a.db))
+	a.health.Register("cache", health.CacheCheck(a.cache))
+
+	// Nothing serves uploads yet, so a missing directory is only reported
+	if a.config.Storage.Driver == "local" {
+		a.health.RegisterOptional("storage", health.LocalStorageCheck(a.config.Storage.LocalPath))
+	}
+
+	// Mail delivery failing should not take the API out of rotation
+	if a.config.Email.SMTPHost != "" {
+		a.health.RegisterOptional("smtp", health.SMTPCheck(a.config.Email))
+	}
+}
+
+// userClaimsLoader loads the role and tenant of the user a token pair is
+// issued to, so changes apply from the next refresh
+func userClaimsLoader(users *database.Repository[models.User]) logger.ClaimsLoader {
+	return func(ctx context.Context, subject string) (*logger.Claims, error) {
+		id, err := strconv.ParseUint(subject, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: subject %q is not a user", logger.ErrInvalidRefreshToken, subject)
+		}
+
+		user, err := users.Get(ctx, uint(id))
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, fmt.Errorf("%w: user %d no longer exists", logger.ErrInvalidRefreshToken, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &logger.Claims{Roles: []string{user.Role}, TenantID: user.Tenant}, nil
+	}
+}
+
+// migrate applies all pending migrations
+func migrate(ctx context.Context, db *database.DbInstance, appLogger *logger.Logger) error {
+	migrator, err := newMigrator(db, database.MigratorConfig{Logger: appLogger})
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+
+	appLogger.Info("Migrations completed", map[string]interface{}{
+		"applied": applied,
+	})
+	return nil
+}
+
+// newMigrator creates a Migrator for all of the application's migrations
+func newMigrator(db *database.DbInstance, cfg database.MigratorConfig) (*database.Migrator, error) {
+	all, err := migrations.All()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return database.NewMigrator(db.Db, cfg, all...)
+}
+
+// Run serves requests until ctx is cancelled, then shuts the server down
+func (a *App) Run(ctx context.Context) error {
+	go a.db.ReportStats(ctx, a.config.Database.StatsInterval, a.logger)
+	go a.tokens.CleanupExpired(ctx, a.config.JWT.CleanupInterval, a.logger)
+
+	if err := a.server.Run(ctx); err != nil {
+		a.logger.Error("Server error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+// Close releases all dependencies; the logger is closed last so that
+// failures from the others are still recorded
+func (a *App) Close() error {
+	var errs []error
+
+	// Background tasks such as email sends still use the database
+	if a.tasks != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), a.config.Server.ShutdownTimeout)
+		if err := a.tasks.Wait(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("background tasks did not finish: %w", err))
+		}
+		cancel()
+	}
+
+	if a.cache != nil {
+		if err := a.cache.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close cache: %w", err))
+		}
+	}
+
+	if a.db != nil {
+		if err := a.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close database: %w", err))
+		}
+	}
+
+	for _, err := range errs {
+		a.logger.Error("Shutdown error", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	a.logger.Info("Shutdown complete", nil)
+	if err := a.logger.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close logger: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"example.com/database"
+	"example.com/database/dbtest"
+	"example.com/migrations"
+	"example.com/models"
+	logger "example.com/utils"
+)
+
+func TestUserClaimsLoader(t *testing.T) {
+	ctx := context.Background()
+	all, err := migrations.All()
+	if err != nil {
+		t.Fatalf("migrations.All = %v", err)
+	}
+	users := database.NewRepository[models.User](dbtest.New(t, dbtest.Options{Migrations: all}))
+
+	user := &models.User{Email: "ada@example.com", PasswordHash: "x", Role: "editor", Tenant: "acme"}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("Create = %v", err)
+	}
+
+	load := userClaimsLoader(users)
+	claims, err := load(ctx, "1")
+	if err != nil {
+		t.Fatalf("load = %v", err)
+	}
+	if !reflect.DeepEqual(claims.Roles, []string{"editor"}) || claims.TenantID != "acme" {
+		t.Errorf("claims = %+v, want role editor and tenant acme", claims)
+	}
+
+	for _, subject := range []string{"2", "cert:billing"} {
+		if _, err := load(ctx, subject); !errors.Is(err, logger.ErrInvalidRefreshToken) {
+			t.Errorf("load(%q) = %v, want ErrInvalidRefreshToken", subject, err)
+		}
+	}
+}
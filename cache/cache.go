@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when a key is missing or has expired
+var ErrNotFound = errors.New("cache: key not found")
+
+// Cache is a key/value store with optional per-entry expiry
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time // zero means no expiry
+}
+
+// expired reports whether the entry has expired at now
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// MemoryCache is an in-process Cache, suitable for development and tests
+type MemoryCache struct {
+	entries map[string]memoryEntry
+	mutex   sync.RWMutex
+}
+
+// NewMemory creates a new empty in-memory cache
+func NewMemory() *MemoryCache {
+	return &MemoryCache{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+// Get returns the value stored under key
+func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mutex.RLock()
+	entry, ok := m.entries[key]
+	m.mutex.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// Expired entries are removed lazily. A Set between the locks may have
+	// replaced the entry, so it is checked again before deleting.
+	if entry.expired(time.Now()) {
+		m.mutex.Lock()
+		if current, ok := m.entries[key]; ok && current.expired(time.Now()) {
+			delete(m.entries, key)
+		}
+		m.mutex.Unlock()
+		return nil, ErrNotFound
+	}
+
+	return entry.value, nil
+}
+
+// Set stores value under key; a ttl of zero keeps the entry until deleted
+func (m *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	m.mutex.Lock()
+	m.entries[key] = entry
+	m.mutex.Unlock()
+
+	return nil
+}
+
+// Delete removes key from the cache
+func (m *MemoryCache) Delete(ctx context.Context, key string) error {
+	m.mutex.Lock()
+	delete(m.entries, key)
+	m.mutex.Unlock()
+
+	return nil
+}
+
+// Ping always succeeds for the in-memory cache
+func (m *MemoryCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close drops all entries
+func (m *MemoryCache) Close() error {
+	m.mutex.Lock()
+	m.entries = make(map[string]memoryEntry)
+	m.mutex.Unlock()
+
+	return nil
+}
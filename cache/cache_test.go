@@ -0,0 +1,68 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/cache"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemory()
+
+	if _, err := c.Get(ctx, "missing"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Get of missing key = %v, want ErrNotFound", err)
+	}
+
+	if err := c.Set(ctx, "kept", []byte("a"), 0); err != nil {
+		t.Fatalf("Set = %v", err)
+	}
+	if value, err := c.Get(ctx, "kept"); err != nil || string(value) != "a" {
+		t.Errorf("Get = %q, %v, want a", value, err)
+	}
+
+	if err := c.Set(ctx, "expiring", []byte("b"), time.Nanosecond); err != nil {
+		t.Fatalf("Set = %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := c.Get(ctx, "expiring"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Get of expired key = %v, want ErrNotFound", err)
+	}
+
+	if err := c.Delete(ctx, "kept"); err != nil {
+		t.Fatalf("Delete = %v", err)
+	}
+	if _, err := c.Get(ctx, "kept"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Get of deleted key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryCacheExpiryKeepsNewValue(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemory()
+
+	// A Get removing the expired entry must not remove a value Set meanwhile
+	for i := 0; i < 1000; i++ {
+		c.Set(ctx, "key", []byte("old"), time.Nanosecond)
+		time.Sleep(time.Microsecond)
+
+		var wg sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.Get(ctx, "key")
+			}()
+		}
+		c.Set(ctx, "key", []byte("new"), time.Hour)
+		wg.Wait()
+
+		if value, err := c.Get(ctx, "key"); err != nil || string(value) != "new" {
+			t.Fatalf("iteration %d: Get = %q, %v, want new", i, value, err)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"example.com/config"
+	"example.com/database"
+	"example.com/seeds"
+	logger "example.com/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// runServe starts the server and blocks until SIGINT/SIGTERM
+func runServe(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: serve takes no arguments", errUsage)
+	}
+
+	appConfig, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	app, err := NewApp(appConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+
+	// Cancel on SIGINT/SIGTERM so in-flight requests can drain
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	runErr := app.Run(ctx)
+	closeErr := app.Close()
+
+	if runErr != nil {
+		return runErr
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to shutdown cleanly: %w", closeErr)
+	}
+	return nil
+}
+
+// runMigrate dispatches the migrate subcommands
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: migrate requires a subcommand", errUsage)
+	}
+
+	// create only writes files and needs neither config nor a database
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return fmt.Errorf("%w: migrate create requires a name", errUsage)
+		}
+		return createMigration(filepath.Join("migrations", "sql"), args[1])
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of executing it")
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	appConfig, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	appLogger, err := logger.NewLogger(appConfig.Logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer appLogger.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := connectForCommand(ctx, appConfig, appLogger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := newMigrator(db, database.MigratorConfig{
+		Logger: appLogger,
+		DryRun: *dryRun,
+		Output: os.Stdout,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		if flags.NArg() > 0 {
+			return fmt.Errorf("%w: migrate up takes no arguments", errUsage)
+		}
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			fmt.Printf("Dry run: %d migration(s) would be applied\n", applied)
+		} else {
+			fmt.Printf("Applied %d migration(s)\n", applied)
+		}
+
+	case "down":
+		steps := 1
+		if flags.NArg() > 1 {
+			return fmt.Errorf("%w: migrate down takes at most one argument", errUsage)
+		}
+		if flags.NArg() == 1 {
+			if steps, err = strconv.Atoi(flags.Arg(0)); err != nil || steps < 1 {
+				return fmt.Errorf("%w: invalid number of steps %q", errUsage, flags.Arg(0))
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			fmt.Printf("Dry run: %d migration(s) would be rolled back\n", rolledBack)
+		} else {
+			fmt.Printf("Rolled back %d migration(s)\n", rolledBack)
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+
+	default:
+		return fmt.Errorf("%w: unknown migrate subcommand %q", errUsage, args[0])
+	}
+
+	return nil
+}
+
+// runSeed dispatches the seed subcommands
+func runSeed(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: seed requires a subcommand", errUsage)
+	}
+
+	flags := flag.NewFlagSet("seed "+args[0], flag.ContinueOnError)
+	force := flags.Bool("force", false, "run seeds again even if they were already run")
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	appConfig, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Checked before connecting so release deployments never touch the database
+	if appConfig.Server.Mode == gin.ReleaseMode {
+		return database.ErrSeedingDisabled
+	}
+
+	appLogger, err := logger.NewLogger(appConfig.Logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer appLogger.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := connectForCommand(ctx, appConfig, appLogger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	seeder, err := database.NewSeeder(db.Db, database.SeederConfig{
+		Logger: appLogger,
+		Mode:   appConfig.Server.Mode,
+		Force:  *force,
+	}, seeds.All()...)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "run":
+		ran, err := seeder.Run(ctx, flags.Args()...)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Ran %d seed(s)\n", ran)
+
+	case "status":
+		if flags.NArg() > 0 {
+			return fmt.Errorf("%w: seed status takes no arguments", errUsage)
+		}
+		statuses, err := seeder.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSEEDED AT")
+		for _, status := range statuses {
+			seededAt := "pending"
+			if status.Seeded {
+				seededAt = status.SeededAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", status.Name, seededAt)
+		}
+		w.Flush()
+
+	default:
+		return fmt.Errorf("%w: unknown seed subcommand %q", errUsage, args[0])
+	}
+
+	return nil
+}
+
+// connectForCommand connects to the database for a one-off command, always
+// waiting for it even if the server is configured to connect lazily
+func connectForCommand(ctx context.Context, appConfig *config.Config, appLogger *logger.Logger) (*database.DbInstance, error) {
+	dbConfig := appConfig.Database
+	dbConfig.LazyConnect = false
+	return database.Connect(ctx, dbConfig, appLogger)
+}
+
+// migrationNamePattern matches characters not allowed in migration names
+var migrationNamePattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// createMigration writes empty up/down SQL files named after the current UTC time
+func createMigration(dir, name string) error {
+	name = strings.Trim(migrationNamePattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return fmt.Errorf("%w: migration name must contain letters or digits", errUsage)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %s migration for %s\n", direction, name)
+
+		// O_EXCL guards against clobbering a migration created in the same second
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to create migration file: %w", err)
+		}
+		_, err = file.WriteString(content)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write migration file: %w", err)
+		}
+
+		fmt.Println("Created", path)
+	}
+
+	return nil
+}
+
+// runConfig dispatches the config subcommands
+func runConfig(args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return fmt.Errorf("%w: expected config print", errUsage)
+	}
+
+	// Print even an invalid configuration so it can be inspected
+	appConfig, validateErr := config.Load()
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(appConfig.Redacted()); err != nil {
+		return err
+	}
+
+	if validateErr != nil {
+		return fmt.Errorf("invalid config: %w", validateErr)
+	}
+	return nil
+}
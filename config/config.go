@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -21,36 +22,59 @@ type Config struct {
 	Storage  StorageConfig  `json:"storage"`
 	Rate     RateConfig     `json:"rate"`
 	Cors     CorsConfig     `json:"cors"`
+	Tenant   TenantConfig   `json:"tenant"`
 }
 
 type ServerConfig struct {
-	Host         string        `json:"host"`
-	Port         string        `json:"port"`
-	Mode         string        `json:"mode"` // debug, release, test
-	ReadTimeout  time.Duration `json:"read_timeout"`
-	WriteTimeout time.Duration `json:"write_timeout"`
-	IdleTimeout  time.Duration `json:"idle_timeout"`
-	TLS          TLSConfig     `json:"tls"`
+	Host            string        `json:"host"`
+	Port            string        `json:"port"`
+	Mode            string        `json:"mode"` // debug, release, test
+	ReadTimeout     time.Duration `json:"read_timeout"`
+	WriteTimeout    time.Duration `json:"write_timeout"`
+	IdleTimeout     time.Duration `json:"idle_timeout"`
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"` // Deadline for draining in-flight requests
+	HealthTimeout   time.Duration `json:"health_timeout"`   // Per-dependency deadline for readiness checks
+	TrustedProxies  []string      `json:"trusted_proxies"`  // IPs or CIDRs allowed to set the client IP via X-Forwarded-For; none by default
+	TLS             TLSConfig     `json:"tls"`
 }
 
 type TLSConfig struct {
-	Enabled  bool   `json:"enabled"`
-	CertFile string `json:"cert_file"`
-	KeyFile  string `json:"key_file"`
+	Enabled        bool          `json:"enabled"`
+	CertFile       string        `json:"cert_file"`
+	KeyFile        string        `json:"key_file"`
+	MinVersion     string        `json:"min_version"`     // 1.0, 1.1, 1.2, 1.3
+	CipherSuites   []string      `json:"cipher_suites"`   // Go cipher suite names, empty for Go defaults
+	ReloadInterval time.Duration `json:"reload_interval"` // How often cert/key files are checked for changes
+	RedirectHTTP   bool          `json:"redirect_http"`   // Run a plain HTTP listener redirecting to HTTPS
+	RedirectPort   string        `json:"redirect_port"`
+	ClientCAFile   string        `json:"client_ca_file"` // CA bundle for verifying client certificates (mTLS)
+	ClientAuth     string        `json:"client_auth"`    // none, request, verify_if_given, require
+	ClientRoles    []string      `json:"client_roles"`   // "name=role" pairs granting roles to client certificates by name
 }
 
 type DatabaseConfig struct {
-	Driver          string        `json:"driver"`
-	Host            string        `json:"host"`
-	Port            string        `json:"port"`
-	Name            string        `json:"name"`
-	User            string        `json:"user"`
-	Password        string        `json:"password"`
-	SSLMode         string        `json:"ssl_mode"`
-	MaxOpenConns    int           `json:"max_open_conns"`
-	MaxIdleConns    int           `json:"max_idle_conns"`
-	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
-	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time"`
+	Driver             string        `json:"driver"` // postgres, mysql, sqlite
+	Host               string        `json:"host"`
+	Port               string        `json:"port"`
+	Name               string        `json:"name"` // Database name, or file path / ":memory:" for sqlite
+	User               string        `json:"user"`
+	Password           string        `json:"password"`
+	SSLMode            string        `json:"ssl_mode"`
+	MaxOpenConns       int           `json:"max_open_conns"`
+	MaxIdleConns       int           `json:"max_idle_conns"`
+	ConnMaxLifetime    time.Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime    time.Duration `json:"conn_max_idle_time"`
+	MigrateOnStart     bool          `json:"migrate_on_start"`     // Apply pending migrations when the server starts
+	LogLevel           string        `json:"log_level"`            // silent, error, warn, info
+	SlowQueryThreshold time.Duration `json:"slow_query_threshold"` // Queries slower than this are logged at warn, 0 disables
+	RedactParams       bool          `json:"redact_params"`        // Log SQL with placeholders instead of parameter values
+	Replicas           []string      `json:"replicas"`             // Read replica hosts as host or host:port
+	StickyPrimary      time.Duration `json:"sticky_primary"`       // How long a client's reads stay on the primary after it writes
+	RetryInitial       time.Duration `json:"retry_initial"`        // First delay between connection attempts, doubled after each failure
+	RetryMaxInterval   time.Duration `json:"retry_max_interval"`   // Upper bound for the delay between attempts
+	RetryMaxWait       time.Duration `json:"retry_max_wait"`       // Give up connecting after this long, 0 disables retries
+	LazyConnect        bool          `json:"lazy_connect"`         // Start without waiting for the database; readiness stays false until it is up
+	StatsInterval      time.Duration `json:"stats_interval"`       // How often pool statistics are logged, 0 disables
 }
 
 type RedisConfig struct {
@@ -61,20 +85,34 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret          string        `json:"secret"`
-	AccessTokenTTL  time.Duration `json:"access_token_ttl"`
-	RefreshTokenTTL time.Duration `json:"refresh_token_ttl"`
-	Issuer          string        `json:"issuer"`
-	CleanupInterval time.Duration `json:"cleanup_interval"`
+	Secret           string        `json:"secret"` // HS256 only
+	AccessTokenTTL   time.Duration `json:"access_token_ttl"`
+	RefreshTokenTTL  time.Duration `json:"refresh_token_ttl"`
+	Issuer           string        `json:"issuer"`
+	CleanupInterval  time.Duration `json:"cleanup_interval"`
+	Algorithm        string        `json:"algorithm"`         // HS256, RS256, ES256, EdDSA
+	PrivateKeyFile   string        `json:"private_key_file"`  // PEM signing key for RS256, ES256 and EdDSA
+	KeyID            string        `json:"key_id"`            // Sent as the kid header of issued tokens
+	VerificationKeys []string      `json:"verification_keys"` // Extra PEM public keys as kid=path, e.g. for rotation
+	TokenSources     []string      `json:"token_sources"`     // Tried in order: header, cookie, query
+	CookieName       string        `json:"cookie_name"`       // Cookie carrying the access token
+	QueryParam       string        `json:"query_param"`       // Query parameter carrying the token on websocket upgrades
+	CookieDomain     string        `json:"cookie_domain"`
+	CookieSecure     bool          `json:"cookie_secure"`    // Only send token cookies over HTTPS
+	CookieSameSite   string        `json:"cookie_same_site"` // lax, strict, none
 }
 
 type EmailConfig struct {
-	SMTPHost     string `json:"smtp_host"`
-	SMTPPort     int    `json:"smtp_port"`
-	SMTPUsername string `json:"smtp_username"`
-	SMTPPassword string `json:"smtp_password"`
-	FromEmail    string `json:"from_email"`
-	FromName     string `json:"from_name"`
+	SMTPHost         string        `json:"smtp_host"`     // Emails are only logged when empty
+	SMTPPort         int           `json:"smtp_port"`     // 465 uses implicit TLS, others STARTTLS
+	SMTPInsecure     bool          `json:"smtp_insecure"` // Send without TLS when the server does not offer STARTTLS
+	SMTPUsername     string        `json:"smtp_username"`
+	SMTPPassword     string        `json:"smtp_password"`
+	FromEmail        string        `json:"from_email"`
+	FromName         string        `json:"from_name"`
+	LinkBaseURL      string        `json:"link_base_url"`      // Base of the links in emails, e.g. the frontend URL
+	VerificationTTL  time.Duration `json:"verification_ttl"`   // Lifetime of email verification tokens
+	PasswordResetTTL time.Duration `json:"password_reset_ttl"` // Lifetime of password reset tokens
 }
 
 type LoggerConfig struct {
@@ -124,6 +162,14 @@ type CorsConfig struct {
 	MaxAge           int      `json:"max_age"`
 }
 
+type TenantConfig struct {
+	Enabled    bool     `json:"enabled"`
+	Sources    []string `json:"sources"`     // Tried in order: claim, subdomain, header; header alone trusts the client
+	Header     string   `json:"header"`      // Request header carrying the tenant ID
+	BaseDomain string   `json:"base_domain"` // Tenant subdomains are <tenant>.<base domain>
+	Claim      string   `json:"claim"`       // JWT claim carrying the tenant ID
+}
+
 // Load loads configuration from environment variables and .env file
 func Load() (*Config, error) {
 	// Load .env file if it exists
@@ -134,30 +180,52 @@ func Load() (*Config, error) {
 
 	config := &Config{
 		Server: ServerConfig{
-			Host:         getEnv("SERVER_HOST", "0.0.0.0"),
-			Port:         getEnv("SERVER_PORT", "8080"),
-			Mode:         getEnv("GIN_MODE", "debug"),
-			ReadTimeout:  getDurationEnv("SERVER_READ_TIMEOUT", 30*time.Second),
-			WriteTimeout: getDurationEnv("SERVER_WRITE_TIMEOUT", 30*time.Second),
-			IdleTimeout:  getDurationEnv("SERVER_IDLE_TIMEOUT", 120*time.Second),
+			Host:            getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:            getEnv("SERVER_PORT", "8080"),
+			Mode:            getEnv("GIN_MODE", "debug"),
+			ReadTimeout:     getDurationEnv("SERVER_READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:    getDurationEnv("SERVER_WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:     getDurationEnv("SERVER_IDLE_TIMEOUT", 120*time.Second),
+			ShutdownTimeout: getDurationEnv("SERVER_SHUTDOWN_TIMEOUT", 15*time.Second),
+			HealthTimeout:   getDurationEnv("SERVER_HEALTH_TIMEOUT", 2*time.Second),
+			TrustedProxies:  getSliceEnv("SERVER_TRUSTED_PROXIES", nil),
 			TLS: TLSConfig{
-				Enabled:  getBoolEnv("TLS_ENABLED", false),
-				CertFile: getEnv("TLS_CERT_FILE", ""),
-				KeyFile:  getEnv("TLS_KEY_FILE", ""),
+				Enabled:        getBoolEnv("TLS_ENABLED", false),
+				CertFile:       getEnv("TLS_CERT_FILE", ""),
+				KeyFile:        getEnv("TLS_KEY_FILE", ""),
+				MinVersion:     getEnv("TLS_MIN_VERSION", "1.2"),
+				CipherSuites:   getSliceEnv("TLS_CIPHER_SUITES", nil),
+				ReloadInterval: getDurationEnv("TLS_RELOAD_INTERVAL", 30*time.Second),
+				RedirectHTTP:   getBoolEnv("TLS_REDIRECT_HTTP", false),
+				RedirectPort:   getEnv("TLS_REDIRECT_PORT", "80"),
+				ClientCAFile:   getEnv("TLS_CLIENT_CA_FILE", ""),
+				ClientAuth:     getEnv("TLS_CLIENT_AUTH", "verify_if_given"),
+				ClientRoles:    getSliceEnv("TLS_CLIENT_ROLES", nil),
 			},
 		},
 		Database: DatabaseConfig{
-			Driver:          getEnv("DB_DRIVER", "postgres"),
-			Host:            getEnv("DB_HOST", "localhost"),
-			Port:            getEnv("DB_PORT", "5432"),
-			Name:            getEnv("DB_NAME", "prohealium"),
-			User:            getEnv("DB_USER", "postgres"),
-			Password:        getEnv("DB_PASSWORD", ""),
-			SSLMode:         getEnv("DB_SSL_MODE", "disable"),
-			MaxOpenConns:    getIntEnv("DB_MAX_OPEN_CONNS", 25),
-			MaxIdleConns:    getIntEnv("DB_MAX_IDLE_CONNS", 25),
-			ConnMaxLifetime: getDurationEnv("DB_CONN_MAX_LIFETIME", 5*time.Minute),
-			ConnMaxIdleTime: getDurationEnv("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+			Driver:             getEnv("DB_DRIVER", "postgres"),
+			Host:               getEnv("DB_HOST", "localhost"),
+			Port:               getEnv("DB_PORT", "5432"),
+			Name:               getEnv("DB_NAME", "prohealium"),
+			User:               getEnv("DB_USER", "postgres"),
+			Password:           getEnv("DB_PASSWORD", ""),
+			SSLMode:            getEnv("DB_SSL_MODE", "disable"),
+			MaxOpenConns:       getIntEnv("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:       getIntEnv("DB_MAX_IDLE_CONNS", 25),
+			ConnMaxLifetime:    getDurationEnv("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+			ConnMaxIdleTime:    getDurationEnv("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+			MigrateOnStart:     getBoolEnv("DB_MIGRATE_ON_START", true),
+			LogLevel:           getEnv("DB_LOG_LEVEL", "warn"),
+			SlowQueryThreshold: getDurationEnv("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+			RedactParams:       getBoolEnv("DB_LOG_REDACT_PARAMS", true),
+			Replicas:           getSliceEnv("DB_REPLICAS", nil),
+			StickyPrimary:      getDurationEnv("DB_STICKY_PRIMARY", 5*time.Second),
+			RetryInitial:       getDurationEnv("DB_RETRY_INITIAL", 500*time.Millisecond),
+			RetryMaxInterval:   getDurationEnv("DB_RETRY_MAX_INTERVAL", 10*time.Second),
+			RetryMaxWait:       getDurationEnv("DB_RETRY_MAX_WAIT", 1*time.Minute),
+			LazyConnect:        getBoolEnv("DB_LAZY_CONNECT", false),
+			StatsInterval:      getDurationEnv("DB_STATS_INTERVAL", 1*time.Minute),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -166,19 +234,33 @@ func Load() (*Config, error) {
 			DB:       getIntEnv("REDIS_DB", 0),
 		},
 		JWT: JWTConfig{
-			Secret:          getEnv("JWT_SECRET", "your-secret-key"),
-			AccessTokenTTL:  getDurationEnv("JWT_ACCESS_TOKEN_TTL", 15*time.Minute),
-			RefreshTokenTTL: getDurationEnv("JWT_REFRESH_TOKEN_TTL", 7*24*time.Hour),
-			Issuer:          getEnv("JWT_ISSUER", "prohealium"),
-			CleanupInterval: getDurationEnv("JWT_CLEANUP_INTERVAL", 1*time.Hour),
+			Secret:           getEnv("JWT_SECRET", "your-secret-key"),
+			AccessTokenTTL:   getDurationEnv("JWT_ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL:  getDurationEnv("JWT_REFRESH_TOKEN_TTL", 7*24*time.Hour),
+			Issuer:           getEnv("JWT_ISSUER", "prohealium"),
+			CleanupInterval:  getDurationEnv("JWT_CLEANUP_INTERVAL", 1*time.Hour),
+			Algorithm:        getEnv("JWT_ALGORITHM", "HS256"),
+			PrivateKeyFile:   getEnv("JWT_PRIVATE_KEY_FILE", ""),
+			KeyID:            getEnv("JWT_KEY_ID", ""),
+			VerificationKeys: getSliceEnv("JWT_VERIFICATION_KEYS", nil),
+			TokenSources:     getSliceEnv("JWT_TOKEN_SOURCES", []string{"header", "cookie"}),
+			CookieName:       getEnv("JWT_COOKIE_NAME", "token"),
+			QueryParam:       getEnv("JWT_QUERY_PARAM", "access_token"),
+			CookieDomain:     getEnv("JWT_COOKIE_DOMAIN", ""),
+			CookieSecure:     getBoolEnv("JWT_COOKIE_SECURE", true),
+			CookieSameSite:   getEnv("JWT_COOKIE_SAME_SITE", "lax"),
 		},
 		Email: EmailConfig{
-			SMTPHost:     getEnv("SMTP_HOST", ""),
-			SMTPPort:     getIntEnv("SMTP_PORT", 587),
-			SMTPUsername: getEnv("SMTP_USERNAME", ""),
-			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
-			FromEmail:    getEnv("FROM_EMAIL", ""),
-			FromName:     getEnv("FROM_NAME", "Prohealium"),
+			SMTPHost:         getEnv("SMTP_HOST", ""),
+			SMTPPort:         getIntEnv("SMTP_PORT", 587),
+			SMTPInsecure:     getBoolEnv("SMTP_INSECURE", false),
+			SMTPUsername:     getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
+			FromEmail:        getEnv("FROM_EMAIL", ""),
+			FromName:         getEnv("FROM_NAME", "Prohealium"),
+			LinkBaseURL:      getEnv("EMAIL_LINK_BASE_URL", "http://localhost:8080"),
+			VerificationTTL:  getDurationEnv("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			PasswordResetTTL: getDurationEnv("EMAIL_PASSWORD_RESET_TTL", time.Hour),
 		},
 		Logger: LoggerConfig{
 			Level:         getEnv("LOG_LEVEL", "debug"),
@@ -219,6 +301,13 @@ func Load() (*Config, error) {
 			AllowCredentials: getBoolEnv("CORS_ALLOW_CREDENTIALS", true),
 			MaxAge:           getIntEnv("CORS_MAX_AGE", 86400),
 		},
+		Tenant: TenantConfig{
+			Enabled:    getBoolEnv("TENANT_ENABLED", false),
+			Sources:    getSliceEnv("TENANT_SOURCES", []string{"claim", "header"}),
+			Header:     getEnv("TENANT_HEADER", "X-Tenant-ID"),
+			BaseDomain: getEnv("TENANT_BASE_DOMAIN", ""),
+			Claim:      getEnv("TENANT_CLAIM", "tenant_id"),
+		},
 	}
 
 	return config, config.Validate()
@@ -226,17 +315,142 @@ func Load() (*Config, error) {
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
-	if c.JWT.Secret == "your-secret-key" && c.Server.Mode != "debug" {
-		return fmt.Errorf("JWT secret must be set in production")
+	switch c.JWT.Algorithm {
+	case "HS256":
+		if c.JWT.Secret == "your-secret-key" && c.Server.Mode != "debug" {
+			return fmt.Errorf("JWT secret must be set in production")
+		}
+
+		// HS256 secrets shorter than the hash output can be brute-forced offline
+		if len(c.JWT.Secret) < 32 && c.Server.Mode != "debug" {
+			return fmt.Errorf("JWT secret must be at least 32 bytes in production")
+		}
+	case "RS256", "ES256", "EdDSA":
+		if c.JWT.PrivateKeyFile == "" {
+			return fmt.Errorf("JWT private key file must be set for %s", c.JWT.Algorithm)
+		}
+	default:
+		return fmt.Errorf("unsupported JWT algorithm %q (supported: HS256, RS256, ES256, EdDSA)", c.JWT.Algorithm)
+	}
+
+	switch c.Database.Driver {
+	case "postgres", "mysql", "sqlite":
+	default:
+		return fmt.Errorf("unsupported database driver %q (supported: postgres, mysql, sqlite)", c.Database.Driver)
+	}
+
+	switch c.Database.LogLevel {
+	case "silent", "error", "warn", "info":
+	default:
+		return fmt.Errorf("unsupported database log level %q (supported: silent, error, warn, info)", c.Database.LogLevel)
+	}
+
+	for _, source := range c.JWT.TokenSources {
+		switch strings.TrimSpace(source) {
+		case "header", "cookie", "query":
+		default:
+			return fmt.Errorf("unsupported JWT token source %q (supported: header, cookie, query)", source)
+		}
+	}
+
+	switch c.JWT.CookieSameSite {
+	case "lax", "strict":
+	case "none":
+		if !c.JWT.CookieSecure {
+			return fmt.Errorf("SameSite=None cookies must be secure")
+		}
+	default:
+		return fmt.Errorf("unsupported JWT cookie SameSite mode %q (supported: lax, strict, none)", c.JWT.CookieSameSite)
+	}
+
+	if c.Database.LazyConnect && c.Database.MigrateOnStart {
+		return fmt.Errorf("lazy database connect requires migrate on start to be disabled")
+	}
+
+	if len(c.Database.Replicas) > 0 && c.Database.Driver == "sqlite" {
+		return fmt.Errorf("read replicas are not supported with sqlite")
 	}
 
-	if c.Database.Password == "" && c.Server.Mode != "debug" {
+	if c.Database.Password == "" && c.Database.Driver != "sqlite" && c.Server.Mode != "debug" {
 		return fmt.Errorf("database password must be set in production")
 	}
 
+	if c.Server.TLS.Enabled && (c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "") {
+		return fmt.Errorf("TLS cert and key files must be set when TLS is enabled")
+	}
+
+	if c.Server.TLS.ClientCAFile != "" && !c.Server.TLS.Enabled {
+		return fmt.Errorf("TLS must be enabled to verify client certificates")
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("trusted proxy %q must be an IP or CIDR", proxy)
+		}
+	}
+
+	for _, pair := range c.Server.TLS.ClientRoles {
+		i := strings.LastIndex(pair, "=")
+		if i < 0 || strings.TrimSpace(pair[:i]) == "" || strings.TrimSpace(pair[i+1:]) == "" {
+			return fmt.Errorf("client role %q must be of the form name=role", pair)
+		}
+	}
+
+	if c.Email.SMTPHost != "" && c.Email.FromEmail == "" {
+		return fmt.Errorf("from email must be set when SMTP is configured")
+	}
+
+	if c.Email.VerificationTTL <= 0 || c.Email.PasswordResetTTL <= 0 {
+		return fmt.Errorf("email token TTLs must be positive")
+	}
+
+	for _, source := range c.Tenant.Sources {
+		switch strings.TrimSpace(source) {
+		case "claim", "header":
+		case "subdomain":
+			if c.Tenant.Enabled && c.Tenant.BaseDomain == "" {
+				return fmt.Errorf("tenant base domain must be set to resolve tenants from subdomains")
+			}
+		default:
+			return fmt.Errorf("unsupported tenant source %q (supported: claim, subdomain, header)", source)
+		}
+	}
+
 	return nil
 }
 
+// Redacted returns a copy of the configuration with secrets masked, safe for printing
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.Database.Password = redact(c.Database.Password)
+	redacted.Redis.Password = redact(c.Redis.Password)
+	redacted.JWT.Secret = redact(c.JWT.Secret)
+	redacted.Email.SMTPPassword = redact(c.Email.SMTPPassword)
+	redacted.Storage.S3.SecretAccessKey = redact(c.Storage.S3.SecretAccessKey)
+	return &redacted
+}
+
+// redact masks a non-empty secret
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "********"
+}
+
+// ForReplica returns a copy of the configuration pointing at a replica host,
+// given as host or host:port
+func (c *DatabaseConfig) ForReplica(host string) DatabaseConfig {
+	replica := *c
+	replica.Replicas = nil
+	replica.Host = strings.TrimSpace(host)
+	if h, port, err := net.SplitHostPort(replica.Host); err == nil {
+		replica.Host, replica.Port = h, port
+	}
+	return replica
+}
+
 // GetDSN returns the database connection string
 func (c *DatabaseConfig) GetDSN() string {
 	switch c.Driver {
@@ -244,8 +458,14 @@ func (c *DatabaseConfig) GetDSN() string {
 		return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 			c.Host, c.Port, c.User, c.Password, c.Name, c.SSLMode)
 	case "mysql":
-		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local&multiStatements=true",
 			c.User, c.Password, c.Host, c.Port, c.Name)
+	case "sqlite":
+		// A shared cache lets every pooled connection see the same in-memory database
+		if c.Name == ":memory:" {
+			return "file::memory:?cache=shared&_foreign_keys=1"
+		}
+		return fmt.Sprintf("file:%s?_foreign_keys=1", c.Name)
 	default:
 		return ""
 	}
@@ -0,0 +1,405 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/config"
+	"example.com/database"
+	"example.com/mail"
+	"example.com/middleware"
+	"example.com/models"
+	"example.com/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// sendTimeout bounds delivering an email in the background
+const sendTimeout = time.Minute
+
+// Limits on password reset requests, which need no sign in. Each one
+// invalidates earlier links and sends an email.
+const (
+	resetsPerEmail = 3 // From one IP
+	resetsPerIP    = 20
+	resetWindow    = time.Hour
+)
+
+// AccountController verifies email addresses and resets and changes passwords
+type AccountController struct {
+	config     config.EmailConfig
+	db         *database.DbInstance
+	users      *database.Repository[models.User]
+	userTokens *database.UserTokenStore
+	tokens     *utils.TokenService
+	mailer     mail.Mailer
+	tasks      *utils.Tasks
+	logger     *utils.Logger
+
+	resetsByEmail *throttle
+	resetsByIP    *throttle
+}
+
+// NewAccountController creates an AccountController. Emails are sent as
+// tasks, so shutdown can wait for them.
+func NewAccountController(cfg config.EmailConfig, db *database.DbInstance, tokens *utils.TokenService, mailer mail.Mailer, tasks *utils.Tasks, log *utils.Logger) *AccountController {
+	return &AccountController{
+		config:        cfg,
+		db:            db,
+		users:         database.NewRepository[models.User](db),
+		userTokens:    database.NewUserTokenStore(db),
+		tokens:        tokens,
+		mailer:        mailer,
+		tasks:         tasks,
+		logger:        log,
+		resetsByEmail: newThrottle(resetsPerEmail, resetWindow),
+		resetsByIP:    newThrottle(resetsPerIP, resetWindow),
+	}
+}
+
+// tokenRequest is the body of a request consuming an emailed token
+type tokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// passwordResetRequest is the body of a request for a password reset email
+type passwordResetRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// passwordResetConfirmRequest is the body of a request setting a new
+// password with a reset token
+type passwordResetConfirmRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
+
+// changePasswordRequest is the body of a request changing the signed in
+// user's password
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8,max=72"`
+}
+
+// RequestVerification emails the signed in user a link to verify their address
+func (a *AccountController) RequestVerification(ctx *gin.Context) {
+	user, ok := a.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": "Conflict",
+			"msg":   "email is already verified",
+		})
+		return
+	}
+
+	if err := a.SendVerification(ctx.Request.Context(), user); err != nil {
+		internalError(ctx, err, "failed to send verification email")
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+// ConfirmVerification marks the email of the user a verification token was
+// sent to as verified
+func (a *AccountController) ConfirmVerification(ctx *gin.Context) {
+	var req tokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, "token is required")
+		return
+	}
+
+	now := time.Now().UTC()
+	err := a.db.WithTx(ctx.Request.Context(), func(txCtx context.Context) error {
+		token, err := a.userTokens.Consume(txCtx, models.TokenPurposeVerifyEmail, utils.HashToken(req.Token), now)
+		if err != nil {
+			return err
+		}
+		return a.users.UpdateFields(txCtx, token.UserID, map[string]interface{}{"email_verified_at": now})
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			badRequest(ctx, "token is invalid or expired")
+			return
+		}
+		internalError(ctx, err, "failed to verify email")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// RequestPasswordReset emails a password reset link. It responds the same
+// whether or not the email is registered, so accounts cannot be enumerated;
+// the lookup runs in the background so timing does not tell either. Requests
+// are limited per email and per client IP.
+func (a *AccountController) RequestPasswordReset(ctx *gin.Context) {
+	var req passwordResetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, "email is required")
+		return
+	}
+
+	// The IP is counted first, and emails per IP, so a client cannot use
+	// up the limit of an address requested from elsewhere
+	now := time.Now()
+	ip := ctx.ClientIP()
+	if !a.resetsByIP.allow(ip, now) || !a.resetsByEmail.allow(ip+" "+normalizeEmail(req.Email), now) {
+		ctx.Header("Retry-After", strconv.Itoa(int(resetWindow/time.Second)))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "Too Many Requests",
+			"msg":   "too many password reset requests, try again later",
+		})
+		return
+	}
+
+	a.background(ctx.Request.Context(), func(bgCtx context.Context) error {
+		user, err := findUserByEmail(bgCtx, a.users, req.Email)
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return a.send(bgCtx, user, models.TokenPurposeResetPassword)
+	})
+
+	ctx.Status(http.StatusAccepted)
+}
+
+// ConfirmPasswordReset sets a new password for the user a reset token was
+// sent to
+func (a *AccountController) ConfirmPasswordReset(ctx *gin.Context) {
+	var req passwordResetConfirmRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, "token and a password of 8 to 72 characters are required")
+		return
+	}
+
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	err = a.db.WithTx(ctx.Request.Context(), func(txCtx context.Context) error {
+		token, err := a.userTokens.Consume(txCtx, models.TokenPurposeResetPassword, utils.HashToken(req.Token), time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		return a.setPassword(txCtx, token.UserID, hash)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			badRequest(ctx, "token is invalid or expired")
+			return
+		}
+		internalError(ctx, err, "failed to reset password")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// ChangePassword sets a new password for the signed in user after checking
+// the current one
+func (a *AccountController) ChangePassword(ctx *gin.Context) {
+	var req changePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, "current_password and a new_password of 8 to 72 characters are required")
+		return
+	}
+
+	user, ok := a.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	if err := utils.CheckPassword(user.PasswordHash, req.CurrentPassword); err != nil {
+		if !errors.Is(err, utils.ErrPasswordMismatch) {
+			internalError(ctx, err, "failed to change password")
+			return
+		}
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error": "Forbidden",
+			"msg":   "current password is incorrect",
+		})
+		return
+	}
+
+	hash, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	err = a.db.WithTx(ctx.Request.Context(), func(txCtx context.Context) error {
+		return a.setPassword(txCtx, user.ID, hash)
+	})
+	if err != nil {
+		internalError(ctx, err, "failed to change password")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// SendVerification emails user a link to verify their address. Earlier
+// verification links stop working.
+func (a *AccountController) SendVerification(ctx context.Context, user *models.User) error {
+	return a.send(ctx, user, models.TokenPurposeVerifyEmail)
+}
+
+// setPassword stores a new password hash and invalidates everything issued
+// under the old password: outstanding emailed tokens and all refresh tokens
+func (a *AccountController) setPassword(ctx context.Context, userID uint, hash string) error {
+	if err := a.users.UpdateFields(ctx, userID, map[string]interface{}{"password_hash": hash}); err != nil {
+		return err
+	}
+	if err := a.userTokens.Invalidate(ctx, userID, time.Now().UTC()); err != nil {
+		return err
+	}
+	return a.tokens.RevokeAll(ctx, strconv.FormatUint(uint64(userID), 10))
+}
+
+// send issues a token for purpose, replacing any earlier ones, and emails
+// the link carrying it to user in the background
+func (a *AccountController) send(ctx context.Context, user *models.User, purpose string) error {
+	template, path, ttl := mail.TemplateVerifyEmail, "/verify-email", a.config.VerificationTTL
+	if purpose == models.TokenPurposeResetPassword {
+		template, path, ttl = mail.TemplateResetPassword, "/reset-password", a.config.PasswordResetTTL
+	}
+
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	err = a.db.WithTx(ctx, func(txCtx context.Context) error {
+		if err := a.userTokens.Invalidate(txCtx, user.ID, now, purpose); err != nil {
+			return err
+		}
+		return a.userTokens.Create(txCtx, &models.UserToken{
+			UserID:    user.ID,
+			Purpose:   purpose,
+			TokenHash: hash,
+			ExpiresAt: now.Add(ttl),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := mail.Render(template, user.Email, map[string]interface{}{
+		"Name":    user.Name,
+		"Link":    a.link(path, token),
+		"Expires": humanDuration(ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	a.background(ctx, func(bgCtx context.Context) error {
+		return a.mailer.Send(bgCtx, msg)
+	})
+	return nil
+}
+
+// background runs fn as a task after the request has been answered, logging
+// its error
+func (a *AccountController) background(ctx context.Context, fn func(ctx context.Context) error) {
+	// Keep request values such as the request ID, but not the cancellation
+	bgCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+
+	a.tasks.Go(func() {
+		defer cancel()
+		if err := fn(bgCtx); err != nil {
+			a.logger.Error("Failed to send email", map[string]interface{}{
+				"error":      err.Error(),
+				"request_id": utils.RequestIDFromContext(ctx),
+			})
+		}
+	})
+}
+
+// link builds the link to path on the configured base URL carrying token
+func (a *AccountController) link(path, token string) string {
+	return strings.TrimSuffix(a.config.LinkBaseURL, "/") + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// currentUser loads the signed in user, responding 404 if there is none
+func (a *AccountController) currentUser(ctx *gin.Context) (*models.User, bool) {
+	user, err := loadUser(ctx, a.users)
+	if err != nil {
+		userError(ctx, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// loadUser loads the user the caller's token was issued to
+func loadUser(ctx *gin.Context, users *database.Repository[models.User]) (*models.User, error) {
+	id, err := strconv.ParseUint(middleware.GetSubject(ctx), 10, 64)
+	if err != nil {
+		return nil, errNotAUser
+	}
+	return users.Get(ctx.Request.Context(), uint(id))
+}
+
+// errNotAUser is returned by loadUser for callers whose subject is not a user ID
+var errNotAUser = errors.New("caller is not a user")
+
+// userError responds to an error from loadUser
+func userError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errNotAUser):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": "Not Found",
+			"msg":   err.Error(),
+		})
+	case errors.Is(err, database.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": "Not Found",
+			"msg":   "user not found",
+		})
+	default:
+		internalError(ctx, err, "failed to load user")
+	}
+}
+
+// findUserByEmail returns the user with the given email
+func findUserByEmail(ctx context.Context, users *database.Repository[models.User], email string) (*models.User, error) {
+	page, err := users.List(ctx, database.ListOptions{
+		Filters: []database.Filter{{Field: "email", Value: normalizeEmail(email)}},
+		Limit:   1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Items) == 0 {
+		return nil, database.ErrNotFound
+	}
+	return &page.Items[0], nil
+}
+
+// humanDuration formats a token lifetime for emails, e.g. "24 hours"
+func humanDuration(d time.Duration) string {
+	unit, n := "minute", int(d/time.Minute)
+	if d >= time.Hour && d%time.Hour == 0 {
+		unit, n = "hour", int(d/time.Hour)
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
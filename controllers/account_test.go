@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/config"
+	"example.com/database"
+	"example.com/database/dbtest"
+	"example.com/mail"
+	"example.com/middleware"
+	"example.com/migrations"
+	"example.com/models"
+	"example.com/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// recordingMailer keeps the emails it is asked to send
+type recordingMailer struct {
+	mutex    sync.Mutex
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// tokenPattern finds the token in an emailed link
+var tokenPattern = regexp.MustCompile(`[?&]token=([^&\s]+)`)
+
+// authTest serves the auth and account handlers on a migrated test database
+type authTest struct {
+	t      *testing.T
+	router *gin.Engine
+	db     *database.DbInstance
+	users  *database.Repository[models.User]
+	mailer *recordingMailer
+	tasks  *utils.Tasks
+}
+
+// newAuthTest wires the auth and account handlers the way the router does
+func newAuthTest(t *testing.T) *authTest {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	all, err := migrations.All()
+	if err != nil {
+		t.Fatalf("migrations.All = %v", err)
+	}
+	db := dbtest.New(t, dbtest.Options{Migrations: all})
+
+	log, err := utils.NewLoggerWithConfig(utils.LoggerConfig{LogDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewLoggerWithConfig = %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+
+	jwtConfig := config.JWTConfig{
+		Secret:          "test-secret-that-is-at-least-32-bytes",
+		Issuer:          "test",
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+		Algorithm:       "HS256",
+		CookieName:      "token",
+	}
+	tokens, err := utils.NewTokenService(jwtConfig, database.NewRefreshTokenStore(db))
+	if err != nil {
+		t.Fatalf("NewTokenService = %v", err)
+	}
+
+	// Background sends finish before the database is closed
+	tasks := utils.NewTasks()
+	t.Cleanup(func() { tasks.Wait(context.Background()) })
+
+	mailer := &recordingMailer{}
+	emailConfig := config.EmailConfig{
+		LinkBaseURL:      "https://app.example.com",
+		VerificationTTL:  24 * time.Hour,
+		PasswordResetTTL: time.Hour,
+	}
+	users := database.NewRepository[models.User](db)
+	accounts := NewAccountController(emailConfig, db, tokens, mailer, tasks, log)
+	auth := NewAuthController(jwtConfig, tokens, users, accounts)
+
+	requireAuth := middleware.AuthMiddleware(tokens)
+	r := gin.New()
+	r.POST("/auth/register", auth.Register)
+	r.POST("/auth/login", auth.Login)
+	r.POST("/auth/logout", auth.Logout)
+	r.POST("/auth/refresh", auth.Refresh)
+	r.GET("/auth/me", requireAuth, auth.Me)
+	r.POST("/auth/verify-email/request", requireAuth, accounts.RequestVerification)
+	r.POST("/auth/verify-email/confirm", accounts.ConfirmVerification)
+	r.POST("/auth/password-reset/request", accounts.RequestPasswordReset)
+	r.POST("/auth/password-reset/confirm", accounts.ConfirmPasswordReset)
+	r.POST("/auth/password", requireAuth, accounts.ChangePassword)
+
+	return &authTest{t: t, router: r, db: db, users: users, mailer: mailer, tasks: tasks}
+}
+
+// post sends body as JSON to path with an optional access token
+func (a *authTest) post(path, accessToken string, body interface{}) *httptest.ResponseRecorder {
+	a.t.Helper()
+
+	content, err := json.Marshal(body)
+	if err != nil {
+		a.t.Fatalf("Marshal = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(content)))
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect fails the test unless w has the wanted status
+func (a *authTest) expect(w *httptest.ResponseRecorder, want int, what string) {
+	a.t.Helper()
+	if w.Code != want {
+		a.t.Fatalf("%s = %d, want %d: %s", what, w.Code, want, w.Body)
+	}
+}
+
+// register registers a user and returns the issued tokens
+func (a *authTest) register(email, password string) authResponse {
+	a.t.Helper()
+
+	w := a.post("/auth/register", "", gin.H{"email": email, "password": password})
+	a.expect(w, http.StatusCreated, "register")
+	return decodeAuthResponse(a.t, w)
+}
+
+// lastToken waits for background sends and returns the token in the link of
+// the last email sent to email
+func (a *authTest) lastToken(email string) string {
+	a.t.Helper()
+
+	if err := a.tasks.Wait(context.Background()); err != nil {
+		a.t.Fatalf("Wait = %v", err)
+	}
+
+	a.mailer.mutex.Lock()
+	defer a.mailer.mutex.Unlock()
+	for i := len(a.mailer.messages) - 1; i >= 0; i-- {
+		msg := a.mailer.messages[i]
+		if msg.To != email {
+			continue
+		}
+		match := tokenPattern.FindStringSubmatch(msg.Body)
+		if match == nil {
+			a.t.Fatalf("email %q carries no token link", msg.Subject)
+		}
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			a.t.Fatalf("QueryUnescape = %v", err)
+		}
+		return token
+	}
+	a.t.Fatalf("no email was sent to %s", email)
+	return ""
+}
+
+// decodeAuthResponse decodes the tokens and user returned by a sign in
+func decodeAuthResponse(t *testing.T, w *httptest.ResponseRecorder) authResponse {
+	t.Helper()
+
+	var resp authResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.TokenPair == nil {
+		t.Fatalf("auth response = %s, %v", w.Body, err)
+	}
+	return resp
+}
+
+func TestPasswordResetTokenIsSingleUse(t *testing.T) {
+	a := newAuthTest(t)
+	a.register("ada@example.com", "old password")
+
+	a.expect(a.post("/auth/password-reset/request", "", gin.H{"email": "ADA@example.com"}), http.StatusAccepted, "reset request")
+	token := a.lastToken("ada@example.com")
+
+	confirm := gin.H{"token": token, "password": "new password"}
+	a.expect(a.post("/auth/password-reset/confirm", "", confirm), http.StatusNoContent, "reset confirm")
+	a.expect(a.post("/auth/password-reset/confirm", "", gin.H{"token": token, "password": "other password"}), http.StatusBadRequest, "second reset confirm")
+
+	a.expect(a.post("/auth/login", "", gin.H{"email": "ada@example.com", "password": "old password"}), http.StatusUnauthorized, "login with old password")
+	a.expect(a.post("/auth/login", "", gin.H{"email": "ada@example.com", "password": "new password"}), http.StatusOK, "login with new password")
+}
+
+func TestPasswordResetForUnknownEmail(t *testing.T) {
+	a := newAuthTest(t)
+
+	a.expect(a.post("/auth/password-reset/request", "", gin.H{"email": "nobody@example.com"}), http.StatusAccepted, "reset request")
+	a.tasks.Wait(context.Background())
+	if len(a.mailer.messages) != 0 {
+		t.Errorf("sent %d emails for an unknown address", len(a.mailer.messages))
+	}
+}
+
+func TestPasswordResetReplacesEarlierTokens(t *testing.T) {
+	a := newAuthTest(t)
+	a.register("ada@example.com", "old password")
+
+	a.expect(a.post("/auth/password-reset/request", "", gin.H{"email": "ada@example.com"}), http.StatusAccepted, "reset request")
+	first := a.lastToken("ada@example.com")
+	a.expect(a.post("/auth/password-reset/request", "", gin.H{"email": "ada@example.com"}), http.StatusAccepted, "second reset request")
+	second := a.lastToken("ada@example.com")
+
+	a.expect(a.post("/auth/password-reset/confirm", "", gin.H{"token": first, "password": "new password"}), http.StatusBadRequest, "confirm with replaced token")
+	a.expect(a.post("/auth/password-reset/confirm", "", gin.H{"token": second, "password": "new password"}), http.StatusNoContent, "confirm with latest token")
+}
+
+func TestPasswordResetTokenExpires(t *testing.T) {
+	a := newAuthTest(t)
+	a.register("ada@example.com", "old password")
+
+	a.expect(a.post("/auth/password-reset/request", "", gin.H{"email": "ada@example.com"}), http.StatusAccepted, "reset request")
+	token := a.lastToken("ada@example.com")
+
+	expired := time.Now().UTC().Add(-time.Minute)
+	if err := a.db.Db.Model(&models.UserToken{}).Where("purpose = ?", models.TokenPurposeResetPassword).Update("expires_at", expired).Error; err != nil {
+		t.Fatalf("Update = %v", err)
+	}
+
+	a.expect(a.post("/auth/password-reset/confirm", "", gin.H{"token": token, "password": "new password"}), http.StatusBadRequest, "confirm with expired token")
+}
+
+func TestChangePasswordInvalidatesTokens(t *testing.T) {
+	a := newAuthTest(t)
+	session := a.register("ada@example.com", "old password")
+	verifyToken := a.lastToken("ada@example.com")
+
+	a.expect(a.post("/auth/password-reset/request", "", gin.H{"email": "ada@example.com"}), http.StatusAccepted, "reset request")
+	resetToken := a.lastToken("ada@example.com")
+
+	change := gin.H{"current_password": "wrong password", "new_password": "new password"}
+	a.expect(a.post("/auth/password", session.AccessToken, change), http.StatusForbidden, "change with wrong password")
+	change["current_password"] = "old password"
+	a.expect(a.post("/auth/password", session.AccessToken, change), http.StatusNoContent, "change password")
+
+	a.expect(a.post("/auth/password-reset/confirm", "", gin.H{"token": resetToken, "password": "other password"}), http.StatusBadRequest, "reset after change")
+	a.expect(a.post("/auth/verify-email/confirm", "", gin.H{"token": verifyToken}), http.StatusBadRequest, "verify after change")
+	a.expect(a.post("/auth/refresh", "", gin.H{"refresh_token": session.RefreshToken}), http.StatusUnauthorized, "refresh after change")
+	a.expect(a.post("/auth/login", "", gin.H{"email": "ada@example.com", "password": "new password"}), http.StatusOK, "login with new password")
+}
+
+func TestVerifyEmail(t *testing.T) {
+	a := newAuthTest(t)
+	session := a.register("ada@example.com", "password1")
+	token := a.lastToken("ada@example.com")
+
+	// A new link replaces the one sent on registration
+	a.expect(a.post("/auth/verify-email/request", session.AccessToken, nil), http.StatusAccepted, "verification request")
+	latest := a.lastToken("ada@example.com")
+	a.expect(a.post("/auth/verify-email/confirm", "", gin.H{"token": token}), http.StatusBadRequest, "confirm with replaced token")
+
+	a.expect(a.post("/auth/verify-email/confirm", "", gin.H{"token": latest}), http.StatusNoContent, "confirm")
+	a.expect(a.post("/auth/verify-email/confirm", "", gin.H{"token": latest}), http.StatusBadRequest, "second confirm")
+
+	user, err := a.users.Get(context.Background(), session.User.ID)
+	if err != nil || user.EmailVerifiedAt == nil {
+		t.Fatalf("user = %+v, %v, want the email verified", user, err)
+	}
+	a.expect(a.post("/auth/verify-email/request", session.AccessToken, nil), http.StatusConflict, "request when verified")
+}
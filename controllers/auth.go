@@ -0,0 +1,292 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/config"
+	"example.com/database"
+	"example.com/middleware"
+	"example.com/models"
+	"example.com/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// refreshCookie holds the refresh token; it is only sent to /auth
+const refreshCookie = "refresh_token"
+
+// AuthController registers and signs in users and issues and renews tokens
+type AuthController struct {
+	config   config.JWTConfig
+	tokens   *utils.TokenService
+	users    *database.Repository[models.User]
+	accounts *AccountController
+}
+
+// NewAuthController creates an AuthController. Registered users are sent a
+// verification email through accounts unless it is nil.
+func NewAuthController(cfg config.JWTConfig, tokens *utils.TokenService, users *database.Repository[models.User], accounts *AccountController) *AuthController {
+	return &AuthController{
+		config:   cfg,
+		tokens:   tokens,
+		users:    users,
+		accounts: accounts,
+	}
+}
+
+// registerRequest is the body of a registration request
+type registerRequest struct {
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+	Name     string `json:"name" binding:"max=255"`
+}
+
+// loginRequest is the body of a login request
+type loginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// refreshRequest is the body of a refresh or logout request. The refresh
+// token may be sent in its cookie instead.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// authResponse is returned when tokens are issued
+type authResponse struct {
+	*utils.TokenPair
+	User *models.User `json:"user,omitempty"`
+}
+
+// Register creates a user with the viewer role in the request's tenant, if
+// any, signs it in and sends it a verification email
+func (a *AuthController) Register(ctx *gin.Context) {
+	var req registerRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, "email and a password of 8 to 72 characters are required")
+		return
+	}
+
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	user := &models.User{
+		Email:        normalizeEmail(req.Email),
+		Name:         strings.TrimSpace(req.Name),
+		PasswordHash: hash,
+		Role:         middleware.RoleViewer,
+	}
+	if tenantID, ok := middleware.GetTenantID(ctx); ok {
+		user.Tenant = tenantID
+	}
+	if err := a.users.Create(ctx.Request.Context(), user); err != nil {
+		if errors.Is(err, database.ErrDuplicate) {
+			ctx.JSON(http.StatusConflict, gin.H{
+				"error": "Conflict",
+				"msg":   "email is already registered",
+			})
+			return
+		}
+		internalError(ctx, err, "failed to create user")
+		return
+	}
+
+	// The account exists either way; the email can be requested again
+	if a.accounts != nil {
+		if err := a.accounts.SendVerification(ctx.Request.Context(), user); err != nil {
+			ctx.Error(err)
+		}
+	}
+
+	a.signIn(ctx, http.StatusCreated, user)
+}
+
+// Login signs a user in with email and password
+func (a *AuthController) Login(ctx *gin.Context) {
+	var req loginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, "email and password are required")
+		return
+	}
+
+	user, err := findUserByEmail(ctx.Request.Context(), a.users, req.Email)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		internalError(ctx, err, "failed to sign in")
+		return
+	}
+
+	// Unknown emails are checked against a dummy hash so they cannot be told
+	// apart from wrong passwords, by response or by timing
+	hash := ""
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if err := utils.CheckPassword(hash, req.Password); err != nil {
+		if !errors.Is(err, utils.ErrPasswordMismatch) {
+			internalError(ctx, err, "failed to sign in")
+			return
+		}
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Unauthorized",
+			"msg":   "invalid email or password",
+		})
+		return
+	}
+
+	a.signIn(ctx, http.StatusOK, user)
+}
+
+// Logout revokes the refresh token family and clears the token cookies
+func (a *AuthController) Logout(ctx *gin.Context) {
+	if token := a.refreshToken(ctx); token != "" {
+		err := a.tokens.Revoke(ctx.Request.Context(), token)
+		if err != nil && !errors.Is(err, utils.ErrInvalidRefreshToken) {
+			internalError(ctx, err, "failed to sign out")
+			return
+		}
+	}
+
+	a.clearCookies(ctx)
+	ctx.Status(http.StatusNoContent)
+}
+
+// Me returns the signed in user
+func (a *AuthController) Me(ctx *gin.Context) {
+	user, err := loadUser(ctx, a.users)
+	if err != nil {
+		userError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair
+func (a *AuthController) Refresh(ctx *gin.Context) {
+	token := a.refreshToken(ctx)
+	if token == "" {
+		badRequest(ctx, "refresh_token is required")
+		return
+	}
+
+	pair, err := a.tokens.Refresh(ctx.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidRefreshToken) || errors.Is(err, utils.ErrRefreshTokenReused) {
+			a.clearCookies(ctx)
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		internalError(ctx, err, "failed to refresh token")
+		return
+	}
+
+	a.setCookies(ctx, pair)
+	ctx.JSON(http.StatusOK, authResponse{TokenPair: pair})
+}
+
+// JWKS publishes the public keys that verify access tokens
+func (a *AuthController) JWKS(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, a.tokens.JWKS())
+}
+
+// signIn issues a token pair for user, setting it as cookies and returning it
+func (a *AuthController) signIn(ctx *gin.Context, status int, user *models.User) {
+	pair, err := a.tokens.IssuePair(ctx.Request.Context(), strconv.FormatUint(uint64(user.ID), 10))
+	if err != nil {
+		internalError(ctx, err, "failed to issue tokens")
+		return
+	}
+
+	a.setCookies(ctx, pair)
+	ctx.JSON(status, authResponse{TokenPair: pair, User: user})
+}
+
+// refreshToken reads the refresh token from the JSON body or its cookie
+func (a *AuthController) refreshToken(ctx *gin.Context) string {
+	var req refreshRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err == nil && req.RefreshToken != "" {
+			return req.RefreshToken
+		}
+	}
+
+	token, _ := ctx.Cookie(refreshCookie)
+	return token
+}
+
+// setCookies stores both tokens in HttpOnly cookies
+func (a *AuthController) setCookies(ctx *gin.Context, pair *utils.TokenPair) {
+	a.setCookie(ctx, a.config.CookieName, pair.AccessToken, "/", time.Until(pair.AccessTokenExpiresAt))
+	a.setCookie(ctx, refreshCookie, pair.RefreshToken, "/auth", time.Until(pair.RefreshTokenExpiresAt))
+}
+
+// clearCookies expires both token cookies
+func (a *AuthController) clearCookies(ctx *gin.Context) {
+	a.setCookie(ctx, a.config.CookieName, "", "/", -1)
+	a.setCookie(ctx, refreshCookie, "", "/auth", -1)
+}
+
+// setCookie sets an HttpOnly cookie with the configured flags. A negative
+// lifetime deletes the cookie.
+func (a *AuthController) setCookie(ctx *gin.Context, name, value, path string, lifetime time.Duration) {
+	maxAge := -1
+	if lifetime >= 0 {
+		maxAge = int(lifetime / time.Second)
+	}
+
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   a.config.CookieDomain,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   a.config.CookieSecure,
+		SameSite: parseSameSite(a.config.CookieSameSite),
+	})
+}
+
+// parseSameSite converts a SameSite mode name to its http constant
+func parseSameSite(mode string) http.SameSite {
+	switch mode {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// normalizeEmail lower-cases an email so lookups are case-insensitive
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// badRequest responds 400 with the standard error body
+func badRequest(ctx *gin.Context, msg string) {
+	ctx.JSON(http.StatusBadRequest, gin.H{
+		"error": "Bad Request",
+		"msg":   msg,
+	})
+}
+
+// internalError records err for the error logging middleware and responds
+// 500 without exposing it
+func internalError(ctx *gin.Context, err error, msg string) {
+	ctx.Error(err)
+	ctx.JSON(http.StatusInternalServerError, gin.H{
+		"error": "Internal Server Error",
+		"msg":   msg,
+	})
+}
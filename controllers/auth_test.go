@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegister(t *testing.T) {
+	a := newAuthTest(t)
+
+	session := a.register("Ada@Example.com", "password1")
+	if session.User.Email != "ada@example.com" || session.User.Role != middleware.RoleViewer {
+		t.Errorf("registered user = %+v, want a viewer with the email lower-cased", session.User)
+	}
+	if a.lastToken("ada@example.com") == "" {
+		t.Error("no verification email was sent")
+	}
+
+	a.expect(a.post("/auth/register", "", gin.H{"email": "ADA@example.com", "password": "password2"}), http.StatusConflict, "duplicate register")
+	a.expect(a.post("/auth/register", "", gin.H{"email": "bob@example.com", "password": "short"}), http.StatusBadRequest, "register with short password")
+	a.expect(a.post("/auth/register", "", gin.H{"email": "not an email", "password": "password1"}), http.StatusBadRequest, "register with invalid email")
+}
+
+func TestLogin(t *testing.T) {
+	a := newAuthTest(t)
+	a.register("ada@example.com", "password1")
+
+	w := a.post("/auth/login", "", gin.H{"email": "ADA@example.com", "password": "password1"})
+	a.expect(w, http.StatusOK, "login")
+	session := decodeAuthResponse(t, w)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
+	me := httptest.NewRecorder()
+	a.router.ServeHTTP(me, req)
+	a.expect(me, http.StatusOK, "me")
+
+	// Unknown emails and wrong passwords get the same response
+	wrongPassword := a.post("/auth/login", "", gin.H{"email": "ada@example.com", "password": "password2"})
+	unknownEmail := a.post("/auth/login", "", gin.H{"email": "bob@example.com", "password": "password1"})
+	a.expect(wrongPassword, http.StatusUnauthorized, "login with wrong password")
+	a.expect(unknownEmail, http.StatusUnauthorized, "login with unknown email")
+	if wrongPassword.Body.String() != unknownEmail.Body.String() {
+		t.Errorf("responses differ: %s and %s", wrongPassword.Body, unknownEmail.Body)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	a := newAuthTest(t)
+	session := a.register("ada@example.com", "password1")
+
+	w := a.post("/auth/refresh", "", gin.H{"refresh_token": session.RefreshToken})
+	a.expect(w, http.StatusOK, "refresh")
+	refreshed := decodeAuthResponse(t, w)
+
+	a.expect(a.post("/auth/logout", "", gin.H{"refresh_token": refreshed.RefreshToken}), http.StatusNoContent, "logout")
+	a.expect(a.post("/auth/refresh", "", gin.H{"refresh_token": refreshed.RefreshToken}), http.StatusUnauthorized, "refresh after logout")
+
+	// Logging out without a valid token still clears the cookies
+	w = a.post("/auth/logout", "", gin.H{"refresh_token": "unknown"})
+	a.expect(w, http.StatusNoContent, "logout with unknown token")
+	if cookies := w.Result().Cookies(); len(cookies) != 2 || cookies[0].MaxAge >= 0 || cookies[1].MaxAge >= 0 {
+		t.Errorf("cookies = %+v, want both cleared", cookies)
+	}
+}
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"net/http"
+
+	"example.com/health"
+	"github.com/gin-gonic/gin"
+)
+
+// Live reports that the process is running, without checking dependencies
+func Live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": health.StatusUp,
+	})
+}
+
+// Ready reports the status and latency of every registered dependency,
+// responding 503 if any of them is down
+func Ready(registry *health.Registry) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		report := registry.Check(ctx.Request.Context())
+
+		status := http.StatusOK
+		if report.Status != health.StatusUp {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, report)
+	}
+}
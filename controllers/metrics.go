@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"example.com/database"
+	"github.com/gin-gonic/gin"
+)
+
+// poolMetric describes one database pool statistic in Prometheus terms
+type poolMetric struct {
+	name  string
+	kind  string // gauge or counter
+	help  string
+	value func(s database.PoolStats) float64
+}
+
+var poolMetrics = []poolMetric{
+	{"db_pool_max_open_connections", "gauge", "Maximum number of open connections to the database.",
+		func(s database.PoolStats) float64 { return float64(s.MaxOpenConnections) }},
+	{"db_pool_open_connections", "gauge", "Number of established connections, both in use and idle.",
+		func(s database.PoolStats) float64 { return float64(s.OpenConnections) }},
+	{"db_pool_in_use_connections", "gauge", "Number of connections currently in use.",
+		func(s database.PoolStats) float64 { return float64(s.InUse) }},
+	{"db_pool_idle_connections", "gauge", "Number of idle connections.",
+		func(s database.PoolStats) float64 { return float64(s.Idle) }},
+	{"db_pool_wait_count_total", "counter", "Total number of connections waited for.",
+		func(s database.PoolStats) float64 { return float64(s.WaitCount) }},
+	{"db_pool_wait_duration_seconds_total", "counter", "Total time blocked waiting for a new connection.",
+		func(s database.PoolStats) float64 { return s.WaitDuration.Seconds() }},
+	{"db_pool_max_idle_closed_total", "counter", "Total number of connections closed due to MaxIdleConns.",
+		func(s database.PoolStats) float64 { return float64(s.MaxIdleClosed) }},
+	{"db_pool_max_idle_time_closed_total", "counter", "Total number of connections closed due to ConnMaxIdleTime.",
+		func(s database.PoolStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+	{"db_pool_max_lifetime_closed_total", "counter", "Total number of connections closed due to ConnMaxLifetime.",
+		func(s database.PoolStats) float64 { return float64(s.MaxLifetimeClosed) }},
+}
+
+// Metrics serves the database pool statistics in the Prometheus text format
+func Metrics(db *database.DbInstance) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		stats, err := db.Stats()
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		ctx.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		ctx.Status(http.StatusOK)
+		writePoolMetrics(ctx.Writer, stats)
+	}
+}
+
+// writePoolMetrics writes every pool metric, labelled by pool
+func writePoolMetrics(w io.Writer, stats []database.PoolStats) {
+	for _, m := range poolMetrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+		for _, s := range stats {
+			fmt.Fprintf(w, "%s{pool=%q} %g\n", m.name, s.Pool, m.value(s))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+)
+
+// throttle allows up to limit events per key in fixed windows. Counts are
+// kept in memory, so each instance of the API enforces the limit on its own.
+type throttle struct {
+	limit  int
+	window time.Duration
+	mutex  sync.Mutex
+	counts map[string]*throttleCount
+	pruned time.Time
+}
+
+// throttleCount is the number of events for a key in the current window
+type throttleCount struct {
+	events int
+	resets time.Time
+}
+
+// newThrottle creates a throttle
+func newThrottle(limit int, window time.Duration) *throttle {
+	return &throttle{
+		limit:  limit,
+		window: window,
+		counts: make(map[string]*throttleCount),
+	}
+}
+
+// allow records an event for key at now, reporting whether it is within the limit
+func (t *throttle) allow(key string, now time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Drop keys whose window has passed, at most once per window
+	if now.Sub(t.pruned) >= t.window {
+		for k, count := range t.counts {
+			if !now.Before(count.resets) {
+				delete(t.counts, k)
+			}
+		}
+		t.pruned = now
+	}
+
+	count, ok := t.counts[key]
+	if !ok || !now.Before(count.resets) {
+		count = &throttleCount{resets: now.Add(t.window)}
+		t.counts[key] = count
+	}
+	if count.events >= t.limit {
+		return false
+	}
+	count.events++
+	return true
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	th := newThrottle(2, time.Minute)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if !th.allow("a", start) || !th.allow("a", start.Add(time.Second)) {
+		t.Fatal("events within the limit were throttled")
+	}
+	if th.allow("a", start.Add(2*time.Second)) {
+		t.Fatal("event over the limit was allowed")
+	}
+	if !th.allow("b", start.Add(2*time.Second)) {
+		t.Fatal("other key was throttled")
+	}
+	if !th.allow("a", start.Add(time.Minute)) {
+		t.Fatal("event in the next window was throttled")
+	}
+
+	// Keys from past windows are dropped
+	th.allow("c", start.Add(3*time.Minute))
+	if _, ok := th.counts["b"]; ok || len(th.counts) != 1 {
+		t.Errorf("counts = %v, want only c", th.counts)
+	}
+}
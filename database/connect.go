@@ -1,37 +1,243 @@
 package database
 
 import (
-	"log"
-	"os"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 
+	"example.com/config"
+	appLogger "example.com/utils"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
 type DbInstance struct {
 	Db *gorm.DB
+
+	replicas      []*sql.DB          // Read replica pools, owned here so they can be closed
+	cancelConnect context.CancelFunc // Stops a background lazy connect
+}
+
+// Connect opens a database connection using the given configuration and
+// applies its connection pool settings. When replicas are configured, reads
+// are routed to them while writes and transactions stay on the primary.
+//
+// Unless LazyConnect is set, Connect waits for the database to answer,
+// retrying with exponential backoff for up to RetryMaxWait. With LazyConnect
+// it returns immediately and keeps retrying in the background.
+// SQL logs go to log, or are discarded if it is nil.
+func Connect(ctx context.Context, cfg config.DatabaseConfig, log *appLogger.Logger) (*DbInstance, error) {
+	dialector, err := Dialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var gormLogger logger.Interface = logger.Discard
+	if log != nil {
+		level, err := ParseGormLogLevel(cfg.LogLevel)
+		if err != nil {
+			return nil, err
+		}
+		gormLogger = NewGormLogger(log, GormLoggerConfig{
+			Level:         level,
+			SlowThreshold: cfg.SlowQueryThreshold,
+			RedactParams:  cfg.RedactParams,
+		})
+	}
+	gormConfig := &gorm.Config{
+		Logger: gormLogger,
+		// Reachability is checked below so that it can be retried
+		DisableAutomaticPing: true,
+		// Report constraint violations as gorm.ErrDuplicatedKey and friends
+		TranslateError: true,
+	}
+
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the database: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database handle: %w", err)
+	}
+	applyPoolSettings(sqlDB, cfg)
+
+	instance := &DbInstance{Db: db}
+
+	if err := registerTenantCallbacks(db); err != nil {
+		instance.Close()
+		return nil, fmt.Errorf("failed to register tenant callbacks: %w", err)
+	}
+
+	if len(cfg.Replicas) > 0 {
+		if err := instance.connectReplicas(cfg, gormConfig); err != nil {
+			instance.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.LazyConnect {
+		instance.connectInBackground(cfg, log)
+		return instance, nil
+	}
+
+	if err := instance.waitForConnection(ctx, cfg, log); err != nil {
+		instance.Close()
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+// connectReplicas opens a pool per replica and registers them with the
+// dbresolver plugin
+func (d *DbInstance) connectReplicas(cfg config.DatabaseConfig, gormConfig *gorm.Config) error {
+	dialectors := make([]gorm.Dialector, 0, len(cfg.Replicas))
+
+	for _, host := range cfg.Replicas {
+		replicaCfg := cfg.ForReplica(host)
+
+		dialector, err := Dialector(replicaCfg)
+		if err != nil {
+			return err
+		}
+
+		replica, err := gorm.Open(dialector, gormConfig)
+		if err != nil {
+			return fmt.Errorf("failed to connect to replica %s: %w", replicaCfg.Host, err)
+		}
+
+		sqlDB, err := replica.DB()
+		if err != nil {
+			return fmt.Errorf("failed to get replica handle: %w", err)
+		}
+		applyPoolSettings(sqlDB, cfg)
+		d.replicas = append(d.replicas, sqlDB)
+
+		// Hand the already-open pool to the resolver
+		dialectors = append(dialectors, existingConnDialector(cfg.Driver, sqlDB))
+	}
+
+	return d.Db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   dbresolver.RandomPolicy{},
+	}))
 }
 
-var Database DbInstance
+// applyPoolSettings applies the configured connection pool limits
+func applyPoolSettings(sqlDB *sql.DB, cfg config.DatabaseConfig) {
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-func ConnectDb() {
-	dsn := "user=postgres password=adminuser123 dbname=estate host=host.docker.internal port=5432 sslmode=disable"
+	// An in-memory sqlite database is dropped once its last connection
+	// closes, so pooled connections must never expire
+	if cfg.Driver == "sqlite" && cfg.Name == ":memory:" {
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Dialector returns the gorm dialector for the configured driver
+func Dialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "postgres":
+		return postgres.Open(cfg.GetDSN()), nil
+	case "mysql":
+		// The server version would be queried on open, before Connect gets
+		// to retry, failing while the database is still starting. Without it
+		// the dialect assumes MySQL 8 features.
+		return mysql.New(mysql.Config{
+			DSN:                       cfg.GetDSN(),
+			SkipInitializeWithVersion: true,
+		}), nil
+	case "sqlite":
+		return sqlite.Open(cfg.GetDSN()), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+}
+
+// existingConnDialector returns a dialector that reuses an open pool
+func existingConnDialector(driver string, conn *sql.DB) gorm.Dialector {
+	switch driver {
+	case "mysql":
+		return mysql.New(mysql.Config{Conn: conn})
+	case "sqlite":
+		return &sqlite.Dialector{Conn: conn}
+	default:
+		return postgres.New(postgres.Config{Conn: conn})
+	}
+}
+
+// primaryOnly returns db, or a handle on its primary pool when read replicas
+// are registered. The replica resolver moves statements off connections
+// pinned with Connection, even writes, so code that must run every statement
+// on one primary connection uses this handle. It shares db's configuration
+// but not its callbacks, so it must not be used for tenant-scoped queries.
+func primaryOnly(db *gorm.DB) (*gorm.DB, error) {
+	if _, ok := db.Config.Plugins[(&dbresolver.DBResolver{}).Name()]; !ok {
+		return db, nil
+	}
+
+	sqlDB, ok := db.Config.ConnPool.(*sql.DB)
+	if !ok {
+		return nil, fmt.Errorf("database: unexpected connection pool %T", db.Config.ConnPool)
+	}
 
+	config := *db.Config
+	config.Plugins = nil
+	return gorm.Open(existingConnDialector(db.Dialector.Name(), sqlDB), &config)
+}
+
+// Close closes the primary and replica connection pools
+func (d *DbInstance) Close() error {
+	if d.Db == nil {
+		return nil
+	}
+
+	if d.cancelConnect != nil {
+		d.cancelConnect()
+	}
+
+	var errs []error
+	for _, replica := range d.replicas {
+		if err := replica.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	sqlDB, err := d.Db.DB()
 	if err != nil {
-		log.Fatal("Failed to connect to the database! \n", err.Error())
-		os.Exit(2)
+		errs = append(errs, err)
+	} else if err := sqlDB.Close(); err != nil {
+		errs = append(errs, err)
 	}
 
-	log.Println("Connected to the database successfully")
-	db.Logger = logger.Default.LogMode(logger.Info)
-	log.Println("Running Migrations")
-	//TODO: Add migrations
-	db.AutoMigrate()
-	db.Logger = logger.Default.LogMode(logger.Info)
-	log.Println("Migrations completed")
+	return errors.Join(errs...)
+}
 
-	Database = DbInstance{Db: db}
+// Ping verifies that connections to the primary and every replica can be established
+func (d *DbInstance) Ping(ctx context.Context) error {
+	sqlDB, err := d.Db.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("primary: %w", err)
+	}
+
+	for i, replica := range d.replicas {
+		if err := replica.PingContext(ctx); err != nil {
+			return fmt.Errorf("replica %d: %w", i, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,54 @@
+// Package dbtest provides a migrated and seeded database for tests
+package dbtest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"example.com/config"
+	"example.com/database"
+)
+
+// Options selects the schema and data of a test database
+type Options struct {
+	Migrations []database.Migration
+	Seeds      []database.Seed
+}
+
+// New returns a sqlite database private to the test, with the given
+// migrations applied and seeds run. It is closed when the test finishes.
+func New(t testing.TB, opts Options) *database.DbInstance {
+	t.Helper()
+
+	ctx := context.Background()
+	db, err := database.Connect(ctx, config.DatabaseConfig{
+		Driver:       "sqlite",
+		Name:         filepath.Join(t.TempDir(), "test.db"),
+		MaxOpenConns: 1,
+		MaxIdleConns: 1,
+		LogLevel:     "silent",
+	}, nil)
+	if err != nil {
+		t.Fatalf("dbtest: failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := database.NewMigrator(db.Db, database.MigratorConfig{}, opts.Migrations...)
+	if err != nil {
+		t.Fatalf("dbtest: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("dbtest: failed to migrate: %v", err)
+	}
+
+	seeder, err := database.NewSeeder(db.Db, database.SeederConfig{Mode: "test"}, opts.Seeds...)
+	if err != nil {
+		t.Fatalf("dbtest: %v", err)
+	}
+	if _, err := seeder.Run(ctx); err != nil {
+		t.Fatalf("dbtest: failed to seed: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	logger "example.com/utils"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLoggerConfig holds configuration for the gorm logger adapter
+type GormLoggerConfig struct {
+	Level             gormlogger.LogLevel
+	SlowThreshold     time.Duration // Queries slower than this are logged at warn, 0 disables
+	RedactParams      bool          // Log SQL with placeholders instead of parameter values
+	LogRecordNotFound bool          // Log gorm.ErrRecordNotFound as an error
+}
+
+// GormLogger routes gorm's logs through utils.Logger as structured entries
+// tagged with the request ID from the query's context
+type GormLogger struct {
+	logger *logger.Logger
+	config GormLoggerConfig
+}
+
+// NewGormLogger creates a gorm logger backed by appLogger
+func NewGormLogger(appLogger *logger.Logger, config GormLoggerConfig) *GormLogger {
+	return &GormLogger{
+		logger: appLogger,
+		config: config,
+	}
+}
+
+// ParseGormLogLevel converts a level name to a gorm log level
+func ParseGormLogLevel(level string) (gormlogger.LogLevel, error) {
+	switch level {
+	case "silent":
+		return gormlogger.Silent, nil
+	case "error":
+		return gormlogger.Error, nil
+	case "", "warn":
+		return gormlogger.Warn, nil
+	case "info":
+		return gormlogger.Info, nil
+	default:
+		return 0, fmt.Errorf("unsupported database log level %q", level)
+	}
+}
+
+// LogMode returns a copy of the logger with the given level
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.config.Level = level
+	return &clone
+}
+
+// Info logs gorm's informational messages
+func (l *GormLogger) Info(ctx context.Context, message string, data ...interface{}) {
+	if l.config.Level >= gormlogger.Info {
+		l.logger.Info(fmt.Sprintf(message, data...), l.fields(ctx))
+	}
+}
+
+// Warn logs gorm's warnings
+func (l *GormLogger) Warn(ctx context.Context, message string, data ...interface{}) {
+	if l.config.Level >= gormlogger.Warn {
+		l.logger.Warn(fmt.Sprintf(message, data...), l.fields(ctx))
+	}
+}
+
+// Error logs gorm's errors
+func (l *GormLogger) Error(ctx context.Context, message string, data ...interface{}) {
+	if l.config.Level >= gormlogger.Error {
+		l.logger.Error(fmt.Sprintf(message, data...), l.fields(ctx))
+	}
+}
+
+// Trace logs an executed statement: failures at error, slow queries at warn
+// and, at the info level, every other query at debug
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.config.Level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	failed := err != nil && (l.config.LogRecordNotFound || !errors.Is(err, gorm.ErrRecordNotFound))
+	slow := l.config.SlowThreshold > 0 && elapsed > l.config.SlowThreshold
+
+	switch {
+	case failed && l.config.Level >= gormlogger.Error:
+		fields := l.queryFields(ctx, elapsed, fc)
+		fields["error"] = err.Error()
+		l.logger.Error("Database query failed", fields)
+
+	case slow && l.config.Level >= gormlogger.Warn:
+		fields := l.queryFields(ctx, elapsed, fc)
+		fields["slow_threshold_ms"] = l.config.SlowThreshold.Milliseconds()
+		l.logger.Warn("Slow database query", fields)
+
+	case l.config.Level >= gormlogger.Info:
+		l.logger.Debug("Database query", l.queryFields(ctx, elapsed, fc))
+	}
+}
+
+// ParamsFilter implements gorm's logger.ParamsFilter. With RedactParams set,
+// statements are logged with placeholders so parameter values, which may
+// contain personal data, never reach the log files.
+func (l *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.config.RedactParams {
+		return sql, nil
+	}
+	return sql, params
+}
+
+// fields returns the base log fields for ctx
+func (l *GormLogger) fields(ctx context.Context) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if requestID := logger.RequestIDFromContext(ctx); requestID != "" {
+		fields["request_id"] = requestID
+	}
+	return fields
+}
+
+// queryFields returns the log fields describing a traced statement
+func (l *GormLogger) queryFields(ctx context.Context, elapsed time.Duration, fc func() (string, int64)) map[string]interface{} {
+	sql, rows := fc()
+
+	fields := l.fields(ctx)
+	fields["sql"] = sql
+	fields["duration_ms"] = float64(elapsed.Microseconds()) / 1000
+	fields["rows_affected"] = rows
+	return fields
+}
@@ -0,0 +1,455 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	logger "example.com/utils"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// migrationLockName identifies the advisory lock held while migrating
+const migrationLockName = "schema_migrations"
+
+// Migration is a single versioned schema change. Go functions take
+// precedence over SQL when both are set.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+	UpSQL   string
+	DownSQL string
+}
+
+// MigrationRecord is a row of the schema_migrations tracking table
+type MigrationRecord struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName overrides the gorm table name
+func (MigrationRecord) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus reports whether a known migration has been applied
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// MigratorConfig holds configuration for the Migrator
+type MigratorConfig struct {
+	Logger      *logger.Logger // Optional
+	DryRun      bool           // Print pending SQL to Output instead of executing it
+	Output      io.Writer      // Destination for dry-run output
+	LockTimeout time.Duration  // How long to wait for the migration lock (mysql only)
+}
+
+// Migrator applies and rolls back versioned migrations, recording progress in
+// the schema_migrations table. An advisory lock ensures that only one replica
+// migrates at a time.
+type Migrator struct {
+	db         *gorm.DB
+	config     MigratorConfig
+	migrations []Migration
+}
+
+// NewMigrator creates a Migrator for the given migrations, which are sorted by version
+func NewMigrator(db *gorm.DB, config MigratorConfig, migrations ...Migration) (*Migrator, error) {
+	if config.Output == nil {
+		config.Output = io.Discard
+	}
+	if config.LockTimeout == 0 {
+		config.LockTimeout = time.Minute
+	}
+
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("migration %q has invalid version %d", m.Name, m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d (%s, %s)", m.Version, sorted[i-1].Name, m.Name)
+		}
+		if m.Up == nil && m.UpSQL == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up step", m.Version, m.Name)
+		}
+	}
+
+	// Locking, reading what has been applied and migrating must all happen
+	// on one connection to the primary
+	db, err := primaryOnly(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		config:     config,
+		migrations: sorted,
+	}, nil
+}
+
+// Up applies all pending migrations in order and returns how many were
+// applied, or in dry-run mode how many would be
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		done, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			if err := m.apply(conn, migration, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down rolls back the given number of most recently applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	rolledBack := 0
+
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		done, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && rolledBack < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == nil && migration.DownSQL == "" {
+				return fmt.Errorf("migration %d_%s cannot be rolled back: no down step", migration.Version, migration.Name)
+			}
+
+			if err := m.apply(conn, migration, false); err != nil {
+				return err
+			}
+			rolledBack++
+		}
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn := m.db.WithContext(ctx)
+	if err := m.ensureTable(conn); err != nil {
+		return nil, err
+	}
+
+	done, err := m.appliedVersions(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+		if record, ok := done[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// apply runs one migration step and updates the tracking table in a single
+// transaction. Note that MySQL commits DDL implicitly.
+func (m *Migrator) apply(conn *gorm.DB, migration Migration, up bool) error {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+
+	if m.config.DryRun {
+		return m.dryRun(conn, migration, up)
+	}
+
+	m.log("Running migration", migration, direction)
+	start := time.Now()
+
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := runStep(tx, migration, up); err != nil {
+			return err
+		}
+
+		if up {
+			return tx.Create(&MigrationRecord{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now().UTC(),
+			}).Error
+		}
+		return tx.Delete(&MigrationRecord{}, "version = ?", migration.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s (%s) failed: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	if m.config.Logger != nil {
+		m.config.Logger.Info("Migration completed", map[string]interface{}{
+			"version":     migration.Version,
+			"name":        migration.Name,
+			"direction":   direction,
+			"duration_ms": time.Since(start).Milliseconds(),
+		})
+	}
+	return nil
+}
+
+// runStep executes the Go function or SQL for one direction of a migration
+func runStep(tx *gorm.DB, migration Migration, up bool) error {
+	fn, sql := migration.Down, migration.DownSQL
+	if up {
+		fn, sql = migration.Up, migration.UpSQL
+	}
+
+	if fn != nil {
+		return fn(tx)
+	}
+	return tx.Exec(sql).Error
+}
+
+// dryRun writes the statements a migration would execute without running them.
+// Go migrations are rendered through a gorm dry-run session, which cannot
+// evaluate queries whose results drive later statements.
+func (m *Migrator) dryRun(conn *gorm.DB, migration Migration, up bool) (err error) {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+	fmt.Fprintf(m.config.Output, "-- %d_%s (%s)\n", migration.Version, migration.Name, direction)
+
+	recorder := &sqlRecorder{}
+	session := conn.Session(&gorm.Session{DryRun: true, Logger: recorder})
+
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(m.config.Output, "-- statements could not be rendered in dry-run mode: %v\n", r)
+		}
+		for _, statement := range recorder.statements {
+			fmt.Fprintf(m.config.Output, "%s;\n", strings.TrimRight(statement, "; \t\n"))
+		}
+		fmt.Fprintln(m.config.Output)
+	}()
+
+	return runStep(session, migration, up)
+}
+
+// withLock runs fn on a single pooled connection while holding the migration
+// advisory lock, so concurrent replicas wait instead of racing
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	// Migrations operate on every tenant's data
+	ctx = WithoutTenant(ctx)
+
+	return m.db.WithContext(ctx).Connection(func(pinned *gorm.DB) error {
+		// A new session keeps the pinned connection but stops statement
+		// state from leaking between calls
+		conn := pinned.Session(&gorm.Session{NewDB: true})
+
+		// A dry run must not change the database, not even by creating the
+		// tracking table
+		if m.config.DryRun {
+			return fn(conn)
+		}
+
+		unlock, err := m.lock(conn)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if err := m.ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+// lock acquires a session-level advisory lock on conn. SQLite serializes
+// writers itself and has no advisory locks.
+func (m *Migrator) lock(conn *gorm.DB) (func(), error) {
+	switch conn.Dialector.Name() {
+	case "postgres":
+		key := int64(crc32.ChecksumIEEE([]byte(migrationLockName)))
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", key).Error; err != nil {
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		return func() { conn.Exec("SELECT pg_advisory_unlock(?)", key) }, nil
+
+	case "mysql":
+		var acquired int
+		timeout := int(m.config.LockTimeout.Seconds())
+		if err := conn.Raw("SELECT GET_LOCK(?, ?)", migrationLockName, timeout).Scan(&acquired).Error; err != nil {
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if acquired != 1 {
+			return nil, fmt.Errorf("timed out waiting for migration lock after %s", m.config.LockTimeout)
+		}
+		return func() { conn.Exec("SELECT RELEASE_LOCK(?)", migrationLockName) }, nil
+
+	default:
+		return func() {}, nil
+	}
+}
+
+// ensureTable creates the schema_migrations table if it does not exist
+func (m *Migrator) ensureTable(conn *gorm.DB) error {
+	if conn.Migrator().HasTable(&MigrationRecord{}) {
+		return nil
+	}
+	if err := conn.Migrator().CreateTable(&MigrationRecord{}); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// appliedVersions returns the applied migrations keyed by version
+func (m *Migrator) appliedVersions(conn *gorm.DB) (map[int64]MigrationRecord, error) {
+	// Dry runs skip creating the table; without it nothing has been applied
+	if m.config.DryRun && !conn.Migrator().HasTable(&MigrationRecord{}) {
+		return map[int64]MigrationRecord{}, nil
+	}
+
+	var records []MigrationRecord
+	if err := conn.Order("version").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int64]MigrationRecord, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// log records the start of a migration step if a logger is configured
+func (m *Migrator) log(message string, migration Migration, direction string) {
+	if m.config.Logger == nil {
+		return
+	}
+	m.config.Logger.Info(message, map[string]interface{}{
+		"version":   migration.Version,
+		"name":      migration.Name,
+		"direction": direction,
+	})
+}
+
+// migrationFilePattern matches files such as 20240101120000_create_users.up.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrationsFS reads SQL migrations from dir in fsys. Files must be named
+// <version>_<name>.up.sql and <version>_<name>.down.sql; other files are ignored.
+func LoadMigrationsFS(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	var versions []int64
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+			versions = append(versions, version)
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d used by both %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	migrations := make([]Migration, 0, len(versions))
+	for _, version := range versions {
+		migrations = append(migrations, *byVersion[version])
+	}
+	return migrations, nil
+}
+
+// sqlRecorder is a gorm logger that collects the SQL of every traced statement
+type sqlRecorder struct {
+	statements []string
+}
+
+func (r *sqlRecorder) LogMode(gormlogger.LogLevel) gormlogger.Interface { return r }
+
+func (r *sqlRecorder) Info(context.Context, string, ...interface{}) {}
+
+func (r *sqlRecorder) Warn(context.Context, string, ...interface{}) {}
+
+func (r *sqlRecorder) Error(context.Context, string, ...interface{}) {}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
@@ -0,0 +1,283 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"example.com/database"
+	"example.com/database/dbtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// widget is the model the migrator tests create
+type widget struct {
+	ID   uint
+	Name string
+}
+
+// widgetMigrations create and then extend the widgets table
+func widgetMigrations() []database.Migration {
+	return []database.Migration{
+		{
+			Version: 1,
+			Name:    "create_widgets",
+			Up:      func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&widget{}) },
+			Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&widget{}) },
+		},
+		{
+			Version: 2,
+			Name:    "add_widgets_color",
+			UpSQL:   "ALTER TABLE widgets ADD COLUMN color TEXT",
+			DownSQL: "ALTER TABLE widgets DROP COLUMN color",
+		},
+	}
+}
+
+// withEmptyReplica registers a read replica holding no tables, so any read
+// that should go to the primary fails
+func withEmptyReplica(t *testing.T, db *database.DbInstance) {
+	t.Helper()
+
+	replica := sqlite.Open("file:" + filepath.Join(t.TempDir(), "replica.db"))
+	if err := db.Db.Use(dbresolver.Register(dbresolver.Config{Replicas: []gorm.Dialector{replica}})); err != nil {
+		t.Fatalf("Use(dbresolver) = %v", err)
+	}
+}
+
+func TestMigratorWithReplica(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, dbtest.Options{})
+	withEmptyReplica(t, db)
+
+	for _, want := range []int{2, 0} {
+		migrator, err := database.NewMigrator(db.Db, database.MigratorConfig{}, widgetMigrations()...)
+		if err != nil {
+			t.Fatalf("NewMigrator = %v", err)
+		}
+		if applied, err := migrator.Up(ctx); err != nil || applied != want {
+			t.Fatalf("Up = %d, %v, want %d", applied, err, want)
+		}
+	}
+
+	// Other reads still go to the replica
+	if err := db.Db.Find(&[]widget{}).Error; err == nil {
+		t.Fatal("Find on the replica succeeded, want the replica to be empty")
+	}
+
+	seeder, err := database.NewSeeder(db.Db, database.SeederConfig{}, database.Seed{
+		Name: "widgets",
+		Run: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Create(&widget{Name: "sprocket"}).Error
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSeeder = %v", err)
+	}
+	for _, want := range []int{1, 0} {
+		if ran, err := seeder.Run(ctx); err != nil || ran != want {
+			t.Fatalf("Run = %d, %v, want %d", ran, err, want)
+		}
+	}
+}
+
+// newWidgetMigrator returns a migrator for the widget migrations on a fresh
+// database
+func newWidgetMigrator(t *testing.T, config database.MigratorConfig) (*database.DbInstance, *database.Migrator) {
+	t.Helper()
+
+	db := dbtest.New(t, dbtest.Options{})
+	migrator, err := database.NewMigrator(db.Db, config, widgetMigrations()...)
+	if err != nil {
+		t.Fatalf("NewMigrator = %v", err)
+	}
+	return db, migrator
+}
+
+// applied returns which migrations Status reports as applied, in order
+func applied(t *testing.T, migrator *database.Migrator) []bool {
+	t.Helper()
+
+	statuses, err := migrator.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status = %v", err)
+	}
+	out := make([]bool, len(statuses))
+	for i, status := range statuses {
+		if status.Applied != (status.AppliedAt != nil) {
+			t.Errorf("status %d: applied %v at %v", status.Version, status.Applied, status.AppliedAt)
+		}
+		out[i] = status.Applied
+	}
+	return out
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := newWidgetMigrator(t, database.MigratorConfig{})
+	schema := db.Db.Migrator()
+
+	if got := applied(t, migrator); !reflect.DeepEqual(got, []bool{false, false}) {
+		t.Fatalf("applied before Up = %v", got)
+	}
+
+	if n, err := migrator.Up(ctx); err != nil || n != 2 {
+		t.Fatalf("Up = %d, %v, want 2", n, err)
+	}
+	if !schema.HasColumn(&widget{}, "color") {
+		t.Fatal("widgets has no color column after Up")
+	}
+	if got := applied(t, migrator); !reflect.DeepEqual(got, []bool{true, true}) {
+		t.Fatalf("applied after Up = %v", got)
+	}
+
+	if n, err := migrator.Down(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("Down(1) = %d, %v, want 1", n, err)
+	}
+	if !schema.HasTable(&widget{}) || schema.HasColumn(&widget{}, "color") {
+		t.Fatal("Down(1) did not roll back only the latest migration")
+	}
+	if got := applied(t, migrator); !reflect.DeepEqual(got, []bool{true, false}) {
+		t.Fatalf("applied after Down(1) = %v", got)
+	}
+
+	if n, err := migrator.Down(ctx, 5); err != nil || n != 1 {
+		t.Fatalf("Down(5) = %d, %v, want 1", n, err)
+	}
+	if schema.HasTable(&widget{}) {
+		t.Fatal("widgets still exists after rolling everything back")
+	}
+	if n, err := migrator.Down(ctx, 1); err != nil || n != 0 {
+		t.Fatalf("Down with nothing applied = %d, %v, want 0", n, err)
+	}
+
+	// Rolled back migrations apply again
+	if n, err := migrator.Up(ctx); err != nil || n != 2 {
+		t.Fatalf("Up after Down = %d, %v, want 2", n, err)
+	}
+}
+
+func TestMigratorDownWithoutDownStep(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, dbtest.Options{})
+
+	migrator, err := database.NewMigrator(db.Db, database.MigratorConfig{}, database.Migration{
+		Version: 1,
+		Name:    "create_widgets",
+		Up:      func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&widget{}) },
+	})
+	if err != nil {
+		t.Fatalf("NewMigrator = %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up = %v", err)
+	}
+
+	if n, err := migrator.Down(ctx, 1); err == nil || n != 0 {
+		t.Fatalf("Down = %d, %v, want an error", n, err)
+	}
+	if got := applied(t, migrator); !reflect.DeepEqual(got, []bool{true}) {
+		t.Fatalf("applied after failed Down = %v", got)
+	}
+}
+
+func TestMigratorFailedStep(t *testing.T) {
+	ctx := context.Background()
+	migrations := append(widgetMigrations(), database.Migration{
+		Version: 3,
+		Name:    "broken",
+		UpSQL:   "ALTER TABLE missing ADD COLUMN color TEXT",
+	})
+	db := dbtest.New(t, dbtest.Options{})
+	migrator, err := database.NewMigrator(db.Db, database.MigratorConfig{}, migrations...)
+	if err != nil {
+		t.Fatalf("NewMigrator = %v", err)
+	}
+
+	n, err := migrator.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "3_broken") || n != 2 {
+		t.Fatalf("Up = %d, %v, want the two before 3_broken applied", n, err)
+	}
+	if got := applied(t, migrator); !reflect.DeepEqual(got, []bool{true, true, false}) {
+		t.Fatalf("applied = %v", got)
+	}
+}
+
+func TestMigratorDryRun(t *testing.T) {
+	ctx := context.Background()
+	var output bytes.Buffer
+	db, migrator := newWidgetMigrator(t, database.MigratorConfig{DryRun: true, Output: &output})
+
+	if n, err := migrator.Up(ctx); err != nil || n != 2 {
+		t.Fatalf("Up = %d, %v, want 2", n, err)
+	}
+
+	for _, want := range []string{
+		"-- 1_create_widgets (up)",
+		"CREATE TABLE `widgets`",
+		"-- 2_add_widgets_color (up)",
+		"ALTER TABLE widgets ADD COLUMN color TEXT;",
+	} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, output.String())
+		}
+	}
+
+	// dbtest created the tracking table, but nothing may be recorded in it
+	var recorded int64
+	if err := db.Db.Model(&database.MigrationRecord{}).Count(&recorded).Error; err != nil {
+		t.Fatalf("Count = %v", err)
+	}
+	if db.Db.Migrator().HasTable(&widget{}) || recorded != 0 {
+		t.Errorf("dry run changed the database, %d migrations recorded", recorded)
+	}
+}
+
+func TestLoadMigrationsFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/2_add_color.up.sql":      {Data: []byte("ALTER TABLE widgets ADD COLUMN color TEXT")},
+		"sql/2_add_color.down.sql":    {Data: []byte("ALTER TABLE widgets DROP COLUMN color")},
+		"sql/1_create_widgets.up.sql": {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY)")},
+		"sql/README.md":               {Data: []byte("ignored")},
+		"sql/3_draft.sql":             {Data: []byte("ignored")},
+		"sql/old/4_old.up.sql":        {Data: []byte("ignored")},
+	}
+
+	migrations, err := database.LoadMigrationsFS(fsys, "sql")
+	if err != nil {
+		t.Fatalf("LoadMigrationsFS = %v", err)
+	}
+	want := []database.Migration{
+		{Version: 1, Name: "create_widgets", UpSQL: "CREATE TABLE widgets (id INTEGER PRIMARY KEY)"},
+		{Version: 2, Name: "add_color", UpSQL: "ALTER TABLE widgets ADD COLUMN color TEXT", DownSQL: "ALTER TABLE widgets DROP COLUMN color"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Fatalf("migrations = %+v, want %+v", migrations, want)
+	}
+
+	// The loaded migrations run
+	db := dbtest.New(t, dbtest.Options{Migrations: migrations})
+	if !db.Db.Migrator().HasColumn("widgets", "color") {
+		t.Error("widgets has no color column")
+	}
+}
+
+func TestLoadMigrationsFSErrors(t *testing.T) {
+	mismatched := fstest.MapFS{
+		"sql/1_create_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id INTEGER)")},
+		"sql/1_create_gadgets.down.sql": {Data: []byte("DROP TABLE gadgets")},
+	}
+	if _, err := database.LoadMigrationsFS(mismatched, "sql"); err == nil {
+		t.Error("LoadMigrationsFS with one version for two names succeeded")
+	}
+
+	if _, err := database.LoadMigrationsFS(fstest.MapFS{}, "missing"); err == nil {
+		t.Error("LoadMigrationsFS of a missing directory succeeded")
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"example.com/models"
+	"example.com/utils"
+	"gorm.io/gorm"
+)
+
+// RefreshTokenStore persists refresh tokens with gorm
+type RefreshTokenStore struct {
+	db *DbInstance
+}
+
+// NewRefreshTokenStore creates a RefreshTokenStore
+func NewRefreshTokenStore(db *DbInstance) *RefreshTokenStore {
+	return &RefreshTokenStore{db: db}
+}
+
+// conn uses the primary, as tokens are usually read right after being written
+func (s *RefreshTokenStore) conn(ctx context.Context) *gorm.DB {
+	return s.db.Conn(WithPrimary(ctx))
+}
+
+// Create stores a new refresh token
+func (s *RefreshTokenStore) Create(ctx context.Context, token *models.RefreshToken) error {
+	return s.conn(ctx).Create(token).Error
+}
+
+// FindByHash returns the token with the given hash
+func (s *RefreshTokenStore) FindByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := s.conn(ctx).Where("token_hash = ?", hash).Take(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed marks a token as used unless it already was, reporting whether
+// this call did so. Concurrent refreshes with one token see at most one true.
+func (s *RefreshTokenStore) MarkUsed(ctx context.Context, id uint, at time.Time) (bool, error) {
+	result := s.conn(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	return result.RowsAffected == 1, result.Error
+}
+
+// RevokeFamily revokes every token of a family
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	return s.conn(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", at).Error
+}
+
+// RevokeSubject revokes every token issued to a subject
+func (s *RefreshTokenStore) RevokeSubject(ctx context.Context, subject string, at time.Time) error {
+	return s.conn(ctx).Model(&models.RefreshToken{}).
+		Where("subject = ? AND revoked_at IS NULL", subject).
+		Update("revoked_at", at).Error
+}
+
+// DeleteExpired deletes the tokens that expired before the given time
+func (s *RefreshTokenStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result := s.conn(ctx).Where("expires_at < ?", before).Delete(&models.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
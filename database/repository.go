@@ -0,0 +1,548 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Page size limits for List
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	// ErrNotFound is returned when no record matches
+	ErrNotFound = errors.New("database: record not found")
+	// ErrDuplicate is returned when a write violates a unique constraint
+	ErrDuplicate = errors.New("database: duplicate record")
+	// ErrUnknownField is returned when a filter or sort names a field the model lacks
+	ErrUnknownField = errors.New("database: unknown field")
+	// ErrInvalidCursor is returned for malformed or mismatched cursors
+	ErrInvalidCursor = errors.New("database: invalid cursor")
+)
+
+// Operator is a comparison used by a Filter
+type Operator string
+
+// Filter operators
+const (
+	OpEq      Operator = "eq"
+	OpNe      Operator = "ne"
+	OpGt      Operator = "gt"
+	OpGte     Operator = "gte"
+	OpLt      Operator = "lt"
+	OpLte     Operator = "lte"
+	OpLike    Operator = "like"
+	OpIn      Operator = "in"
+	OpIsNull  Operator = "is_null"
+	OpNotNull Operator = "not_null"
+)
+
+// Filter restricts a List query. Field may be the Go field name or the column name.
+type Filter struct {
+	Field    string
+	Operator Operator // Defaults to OpEq
+	Value    interface{}
+}
+
+// Sort orders a List query by a field
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions controls filtering, sorting and pagination for List.
+// Cursor pagination cannot be combined with Offset and supports at most one
+// Sort field besides the primary key, which is always the final tie-breaker.
+// NULLs sort after all values.
+type ListOptions struct {
+	Filters     []Filter
+	Sort        []Sort
+	Limit       int    // Page size, defaults to DefaultPageSize and is capped at MaxPageSize
+	Offset      int    // Offset pagination
+	Cursor      string // Keyset pagination, taken from Page.NextCursor
+	Count       bool   // Populate Page.Total
+	WithDeleted bool   // Include soft-deleted records
+}
+
+// Page is one page of List results
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Repository implements CRUD operations for the gorm model T. Models with a
+// gorm.DeletedAt field are soft-deleted.
+type Repository[T any] struct {
+	db *DbInstance
+}
+
+// NewRepository creates a Repository for the model T
+func NewRepository[T any](db *DbInstance) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+// conn returns the gorm handle bound to ctx, joining its transaction if any
+func (r *Repository[T]) conn(ctx context.Context) *gorm.DB {
+	return r.db.Conn(ctx)
+}
+
+// schema returns the parsed schema of T
+func (r *Repository[T]) schema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: r.db.Db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, fmt.Errorf("failed to parse model: %w", err)
+	}
+	return stmt.Schema, nil
+}
+
+// Create inserts entity, populating its primary key and timestamps
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	if err := r.conn(ctx).Create(entity).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+// byID matches the record with the given primary key. The id is always bound
+// as a value: gorm reads a string passed as an inline condition as SQL.
+func byID(id interface{}) clause.Expression {
+	return clause.Eq{Column: clause.PrimaryColumn, Value: id}
+}
+
+// Get returns the record with the given primary key
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	entity := new(T)
+	if err := r.conn(ctx).Where(byID(id)).First(entity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return entity, nil
+}
+
+// Update saves every field of entity except CreatedAt, including zero values.
+// The entity should have been loaded with Get.
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	sch, err := r.schema()
+	if err != nil {
+		return err
+	}
+	if sch.PrioritizedPrimaryField == nil {
+		return fmt.Errorf("database: %s has no primary key", sch.Name)
+	}
+	id, _ := sch.PrioritizedPrimaryField.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+
+	return r.db.WithTx(ctx, func(txCtx context.Context) error {
+		result := r.conn(txCtx).Model(entity).Select("*").Omit("CreatedAt").Updates(entity)
+		return r.updated(txCtx, result, id)
+	})
+}
+
+// UpdateFields updates only the given fields of the record with the given primary key
+func (r *Repository[T]) UpdateFields(ctx context.Context, id interface{}, fields map[string]interface{}) error {
+	sch, err := r.schema()
+	if err != nil {
+		return err
+	}
+	if len(sch.PrimaryFields) != 1 {
+		return fmt.Errorf("database: UpdateFields requires a single primary key")
+	}
+
+	// Resolve names through the schema so callers cannot inject columns
+	columns := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		field := sch.LookUpField(name)
+		if field == nil {
+			return fmt.Errorf("%w: %s", ErrUnknownField, name)
+		}
+		columns[field.DBName] = value
+	}
+
+	return r.db.WithTx(ctx, func(txCtx context.Context) error {
+		result := r.conn(txCtx).Model(new(T)).Where(byID(id)).Updates(columns)
+		return r.updated(txCtx, result, id)
+	})
+}
+
+// updated returns the error of an update of the record with the given
+// primary key, or ErrNotFound if the record does not exist. MySQL counts only
+// rows whose values changed, so no rows affected alone does not mean the
+// record is missing; ctx must carry the update's transaction.
+func (r *Repository[T]) updated(ctx context.Context, result *gorm.DB, id interface{}) error {
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+
+	var count int64
+	if err := r.conn(ctx).Model(new(T)).Where(byID(id)).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes the record with the given primary key, soft-deleting it
+// if the model supports it
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	return r.delete(r.conn(ctx), id)
+}
+
+// ForceDelete permanently removes the record, even for soft-delete models
+func (r *Repository[T]) ForceDelete(ctx context.Context, id interface{}) error {
+	return r.delete(r.conn(ctx).Unscoped(), id)
+}
+
+func (r *Repository[T]) delete(db *gorm.DB, id interface{}) error {
+	result := db.Where(byID(id)).Delete(new(T))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Restore undeletes a soft-deleted record
+func (r *Repository[T]) Restore(ctx context.Context, id interface{}) error {
+	sch, err := r.schema()
+	if err != nil {
+		return err
+	}
+
+	deletedAt := softDeleteField(sch)
+	if deletedAt == nil {
+		return fmt.Errorf("database: %s does not support soft delete", sch.Name)
+	}
+
+	result := r.conn(ctx).Unscoped().Model(new(T)).
+		Where(byID(id)).
+		Update(deletedAt.DBName, nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// List returns one page of records matching opts
+func (r *Repository[T]) List(ctx context.Context, opts ListOptions) (*Page[T], error) {
+	sch, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.conn(ctx).Model(new(T))
+	if opts.WithDeleted {
+		query = query.Unscoped()
+	}
+
+	for _, filter := range opts.Filters {
+		expr, err := filterExpr(sch, filter)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(expr)
+	}
+
+	page := &Page[T]{}
+	if opts.Count {
+		if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Resolve sort fields, always ending with the primary key so that the
+	// order, and therefore pagination, is stable
+	pk := sch.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, fmt.Errorf("database: %s has no primary key", sch.Name)
+	}
+
+	var sortFields []*schema.Field
+	var sortDesc []bool
+	pkDesc, pkSorted := false, false
+	for _, s := range opts.Sort {
+		field := sch.LookUpField(s.Field)
+		if field == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownField, s.Field)
+		}
+		if field == pk {
+			pkDesc, pkSorted = s.Desc, true
+			break
+		}
+		sortFields = append(sortFields, field)
+		sortDesc = append(sortDesc, s.Desc)
+	}
+	// Without an explicit direction the tie-breaker follows the first sort
+	if !pkSorted && len(sortDesc) > 0 {
+		pkDesc = sortDesc[0]
+	}
+	sortFields = append(sortFields, pk)
+	sortDesc = append(sortDesc, pkDesc)
+
+	// Keyset cursors cover one sort field plus the primary key
+	cursorable := len(sortFields) <= 2 && opts.Offset == 0
+	if opts.Cursor != "" && !cursorable {
+		return nil, fmt.Errorf("%w: cursor pagination supports a single sort field and no offset", ErrInvalidCursor)
+	}
+
+	query = query.Clauses(orderBy(sortFields, sortDesc))
+
+	if opts.Cursor != "" {
+		expr, err := cursorExpr(sch, opts.Cursor, sortFields, sortDesc)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(expr)
+	} else if opts.Offset > 0 {
+		query = query.Offset(opts.Offset)
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	var items []T
+	if err := query.Limit(limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+		if cursorable {
+			cursor, err := encodeCursor(ctx, items[len(items)-1], sortFields)
+			if err != nil {
+				return nil, err
+			}
+			page.NextCursor = cursor
+		}
+	}
+	if items == nil {
+		items = []T{}
+	}
+	page.Items = items
+
+	return page, nil
+}
+
+// orderBy builds the ORDER BY clause for the sort fields. NULLs sort after
+// all values, as on postgres, whatever the driver's own default, so that
+// cursors select the same rows everywhere.
+func orderBy(fields []*schema.Field, desc []bool) clause.OrderBy {
+	order := make([]string, 0, len(fields))
+	vars := make([]interface{}, 0, len(fields))
+	for i, field := range fields {
+		direction := " ASC"
+		if desc[i] {
+			direction = " DESC"
+		}
+
+		column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+		if nullable(field) {
+			order = append(order, "? IS NULL"+direction)
+			vars = append(vars, column)
+		}
+		order = append(order, "?"+direction)
+		vars = append(vars, column)
+	}
+
+	return clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(order, ", "), Vars: vars}}
+}
+
+// filterExpr builds the where clause for a filter, resolving the field
+// through the schema so that only real columns can be referenced
+func filterExpr(sch *schema.Schema, filter Filter) (clause.Expression, error) {
+	field := sch.LookUpField(filter.Field)
+	if field == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownField, filter.Field)
+	}
+	column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+
+	switch filter.Operator {
+	case "", OpEq:
+		return clause.Eq{Column: column, Value: filter.Value}, nil
+	case OpNe:
+		return clause.Neq{Column: column, Value: filter.Value}, nil
+	case OpGt:
+		return clause.Gt{Column: column, Value: filter.Value}, nil
+	case OpGte:
+		return clause.Gte{Column: column, Value: filter.Value}, nil
+	case OpLt:
+		return clause.Lt{Column: column, Value: filter.Value}, nil
+	case OpLte:
+		return clause.Lte{Column: column, Value: filter.Value}, nil
+	case OpLike:
+		return clause.Like{Column: column, Value: filter.Value}, nil
+	case OpIn:
+		values := reflect.ValueOf(filter.Value)
+		if values.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("database: in filter on %s requires a slice", filter.Field)
+		}
+		in := clause.IN{Column: column}
+		for i := 0; i < values.Len(); i++ {
+			in.Values = append(in.Values, values.Index(i).Interface())
+		}
+		return in, nil
+	case OpIsNull:
+		return clause.Eq{Column: column, Value: nil}, nil
+	case OpNotNull:
+		return clause.Neq{Column: column, Value: nil}, nil
+	default:
+		return nil, fmt.Errorf("database: unknown filter operator %q", filter.Operator)
+	}
+}
+
+// cursorPayload is the decoded form of a pagination cursor
+type cursorPayload struct {
+	Fields []string          `json:"f"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// encodeCursor captures the sort key values of the last item on a page
+func encodeCursor(ctx context.Context, item interface{}, fields []*schema.Field) (string, error) {
+	value := reflect.ValueOf(item)
+
+	payload := cursorPayload{}
+	for _, field := range fields {
+		fieldValue, _ := field.ValueOf(ctx, value)
+		raw, err := json.Marshal(fieldValue)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode cursor: %w", err)
+		}
+		payload.Fields = append(payload.Fields, field.DBName)
+		payload.Values = append(payload.Values, raw)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// cursorExpr decodes a cursor into a keyset condition selecting the rows
+// after it in the given sort order
+func cursorExpr(sch *schema.Schema, cursor string, fields []*schema.Field, desc []bool) (clause.Expression, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil || len(payload.Fields) != len(fields) || len(payload.Values) != len(fields) {
+		return nil, ErrInvalidCursor
+	}
+
+	// Decode each value into the field's Go type so it binds correctly
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		if payload.Fields[i] != field.DBName {
+			return nil, ErrInvalidCursor
+		}
+		target := reflect.New(field.FieldType)
+		if err := json.Unmarshal(payload.Values[i], target.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = target.Elem().Interface()
+	}
+
+	after := func(i int) clause.Expression {
+		column := clause.Column{Table: clause.CurrentTable, Name: fields[i].DBName}
+		if desc[i] {
+			return clause.Lt{Column: column, Value: values[i]}
+		}
+		return clause.Gt{Column: column, Value: values[i]}
+	}
+
+	if len(fields) == 1 {
+		return after(0), nil
+	}
+
+	// (sort > v) OR (sort = v AND pk > id)
+	sortColumn := clause.Column{Table: clause.CurrentTable, Name: fields[0].DBName}
+	keyset := clause.Or(
+		after(0),
+		clause.And(clause.Eq{Column: sortColumn, Value: values[0]}, after(1)),
+	)
+	if !nullable(fields[0]) {
+		return keyset, nil
+	}
+
+	// Comparisons with NULL are never true, so NULLs, which sort after all
+	// values, need their own conditions
+	isNull := clause.Eq{Column: sortColumn, Value: nil}
+	switch {
+	case isNullValue(values[0]) && desc[0]:
+		return clause.Or(clause.And(isNull, after(1)), clause.Neq{Column: sortColumn, Value: nil}), nil
+	case isNullValue(values[0]):
+		return clause.And(isNull, after(1)), nil
+	case desc[0]:
+		return keyset, nil
+	default:
+		return clause.Or(keyset, isNull), nil
+	}
+}
+
+// nullable reports whether a field can hold NULL: pointers and types such as
+// sql.NullTime that convert themselves to driver values
+func nullable(field *schema.Field) bool {
+	if field.PrimaryKey || field.NotNull {
+		return false
+	}
+	if field.FieldType.Kind() == reflect.Ptr {
+		return true
+	}
+	_, ok := reflect.New(field.FieldType).Elem().Interface().(driver.Valuer)
+	return ok
+}
+
+// isNullValue reports whether value is stored as NULL
+func isNullValue(value interface{}) bool {
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		return true
+	}
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		return err == nil && v == nil
+	}
+	return false
+}
+
+// softDeleteField returns the gorm.DeletedAt field of a schema, if any
+func softDeleteField(sch *schema.Schema) *schema.Field {
+	deletedAtType := reflect.TypeOf(gorm.DeletedAt{})
+	for _, field := range sch.Fields {
+		if field.FieldType == deletedAtType {
+			return field
+		}
+	}
+	return nil
+}
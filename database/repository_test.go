@@ -0,0 +1,403 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"example.com/database"
+	"example.com/database/dbtest"
+	"gorm.io/gorm"
+)
+
+// doc is the model the repository tests run against
+type doc struct {
+	ID        uint
+	Title     string `gorm:"uniqueIndex"`
+	Rank      int
+	DueAt     *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// newDocRepository returns a repository on a fresh database holding docs
+func newDocRepository(t *testing.T, docs ...doc) *database.Repository[doc] {
+	t.Helper()
+
+	db := dbtest.New(t, dbtest.Options{
+		Migrations: []database.Migration{{
+			Version: 1,
+			Name:    "create_docs",
+			Up:      func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&doc{}) },
+		}},
+	})
+
+	repo := database.NewRepository[doc](db)
+	for i := range docs {
+		if err := repo.Create(context.Background(), &docs[i]); err != nil {
+			t.Fatalf("Create(%q) = %v", docs[i].Title, err)
+		}
+	}
+	return repo
+}
+
+// ids returns the primary keys of docs in order
+func ids(docs []doc) []uint {
+	out := make([]uint, len(docs))
+	for i, d := range docs {
+		out[i] = d.ID
+	}
+	return out
+}
+
+func TestRepositoryCRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := newDocRepository(t)
+
+	d := &doc{Title: "first", Rank: 1}
+	if err := repo.Create(ctx, d); err != nil {
+		t.Fatalf("Create = %v", err)
+	}
+	if d.ID == 0 || d.CreatedAt.IsZero() {
+		t.Fatalf("Create did not populate ID and CreatedAt: %+v", d)
+	}
+
+	got, err := repo.Get(ctx, d.ID)
+	if err != nil || got.Title != "first" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	// Update writes zero values too
+	got.Rank = 0
+	got.Title = "renamed"
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update = %v", err)
+	}
+	if got, _ = repo.Get(ctx, d.ID); got.Rank != 0 || got.Title != "renamed" {
+		t.Fatalf("after Update = %+v", got)
+	}
+
+	if err := repo.UpdateFields(ctx, d.ID, map[string]interface{}{"Rank": 7}); err != nil {
+		t.Fatalf("UpdateFields = %v", err)
+	}
+	if got, _ = repo.Get(ctx, d.ID); got.Rank != 7 || got.Title != "renamed" {
+		t.Fatalf("after UpdateFields = %+v", got)
+	}
+
+	if err := repo.UpdateFields(ctx, d.ID, map[string]interface{}{"rank; DROP TABLE docs": 1}); !errors.Is(err, database.ErrUnknownField) {
+		t.Errorf("UpdateFields with unknown field = %v, want ErrUnknownField", err)
+	}
+	if err := repo.UpdateFields(ctx, 999, map[string]interface{}{"rank": 1}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("UpdateFields of missing record = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.Get(ctx, 999); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Get of missing record = %v, want ErrNotFound", err)
+	}
+}
+
+// tag is a model with a string primary key
+type tag struct {
+	ID   string `gorm:"primaryKey;size:64"`
+	Name string
+}
+
+func TestRepositoryStringIDs(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, dbtest.Options{
+		Migrations: []database.Migration{{
+			Version: 1,
+			Name:    "create_tags",
+			Up:      func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&tag{}) },
+		}},
+	})
+	repo := database.NewRepository[tag](db)
+	for _, id := range []string{"a", "b"} {
+		if err := repo.Create(ctx, &tag{ID: id, Name: "tag " + id}); err != nil {
+			t.Fatalf("Create(%q) = %v", id, err)
+		}
+	}
+
+	if got, err := repo.Get(ctx, "b"); err != nil || got.Name != "tag b" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	if err := repo.UpdateFields(ctx, "b", map[string]interface{}{"name": "renamed"}); err != nil {
+		t.Fatalf("UpdateFields = %v", err)
+	}
+
+	// IDs are values, never SQL
+	for _, id := range []string{"id = 'b' OR 1=1", "1=1"} {
+		if got, err := repo.Get(ctx, id); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Get(%q) = %+v, %v, want ErrNotFound", id, got, err)
+		}
+		if err := repo.Delete(ctx, id); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Delete(%q) = %v, want ErrNotFound", id, err)
+		}
+		if err := repo.ForceDelete(ctx, id); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("ForceDelete(%q) = %v, want ErrNotFound", id, err)
+		}
+	}
+
+	if err := repo.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete = %v", err)
+	}
+	page, err := repo.List(ctx, database.ListOptions{})
+	if err != nil || len(page.Items) != 1 || page.Items[0] != (tag{ID: "b", Name: "renamed"}) {
+		t.Errorf("List = %+v, %v, want only tag b", page, err)
+	}
+}
+
+func TestRepositoryNumericIDsAreValues(t *testing.T) {
+	ctx := context.Background()
+	repo := newDocRepository(t, doc{Title: "a"}, doc{Title: "b"})
+
+	if got, err := repo.Get(ctx, "title = 'b' OR 1=1"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Get = %+v, %v, want ErrNotFound", got, err)
+	}
+	if err := repo.Delete(ctx, "1=1"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Delete = %v, want ErrNotFound", err)
+	}
+	if page, err := repo.List(ctx, database.ListOptions{}); err != nil || len(page.Items) != 2 {
+		t.Errorf("List = %v, %v, want both docs", ids(page.Items), err)
+	}
+}
+
+func TestRepositoryUpdateUnchanged(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t, dbtest.Options{
+		Migrations: []database.Migration{{
+			Version: 1,
+			Name:    "create_docs",
+			Up:      func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&doc{}) },
+		}},
+	})
+
+	// Report updates the way MySQL does when no value changes
+	err := db.Db.Callback().Update().After("gorm:update").Register("test:unchanged", func(tx *gorm.DB) {
+		tx.RowsAffected = 0
+	})
+	if err != nil {
+		t.Fatalf("Register = %v", err)
+	}
+
+	repo := database.NewRepository[doc](db)
+	d := &doc{Title: "a", Rank: 1}
+	if err := repo.Create(ctx, d); err != nil {
+		t.Fatalf("Create = %v", err)
+	}
+
+	if err := repo.Update(ctx, d); err != nil {
+		t.Errorf("Update = %v", err)
+	}
+	if err := repo.UpdateFields(ctx, d.ID, map[string]interface{}{"rank": 1}); err != nil {
+		t.Errorf("UpdateFields = %v", err)
+	}
+
+	if err := repo.Update(ctx, &doc{ID: 999, Title: "missing"}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Update of missing record = %v, want ErrNotFound", err)
+	}
+	if err := repo.UpdateFields(ctx, 999, map[string]interface{}{"rank": 1}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("UpdateFields of missing record = %v, want ErrNotFound", err)
+	}
+
+	if err := repo.Delete(ctx, d.ID); err != nil {
+		t.Fatalf("Delete = %v", err)
+	}
+	if err := repo.UpdateFields(ctx, d.ID, map[string]interface{}{"rank": 2}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("UpdateFields of deleted record = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRepositoryCreateDuplicate(t *testing.T) {
+	ctx := context.Background()
+	repo := newDocRepository(t, doc{Title: "taken"})
+
+	if err := repo.Create(ctx, &doc{Title: "taken"}); !errors.Is(err, database.ErrDuplicate) {
+		t.Fatalf("Create of duplicate = %v, want ErrDuplicate", err)
+	}
+}
+
+func TestRepositorySoftDelete(t *testing.T) {
+	ctx := context.Background()
+	repo := newDocRepository(t, doc{Title: "a"}, doc{Title: "b"})
+
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete = %v", err)
+	}
+	if _, err := repo.Get(ctx, 1); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(ctx, 1); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("second Delete = %v, want ErrNotFound", err)
+	}
+
+	page, err := repo.List(ctx, database.ListOptions{})
+	if err != nil || !reflect.DeepEqual(ids(page.Items), []uint{2}) {
+		t.Fatalf("List after Delete = %v, %v", ids(page.Items), err)
+	}
+	page, err = repo.List(ctx, database.ListOptions{WithDeleted: true})
+	if err != nil || !reflect.DeepEqual(ids(page.Items), []uint{1, 2}) {
+		t.Fatalf("List WithDeleted = %v, %v", ids(page.Items), err)
+	}
+
+	if err := repo.Restore(ctx, 1); err != nil {
+		t.Fatalf("Restore = %v", err)
+	}
+	if _, err := repo.Get(ctx, 1); err != nil {
+		t.Fatalf("Get after Restore = %v", err)
+	}
+
+	if err := repo.ForceDelete(ctx, 1); err != nil {
+		t.Fatalf("ForceDelete = %v", err)
+	}
+	if err := repo.Restore(ctx, 1); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Restore after ForceDelete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRepositoryListFilters(t *testing.T) {
+	repo := newDocRepository(t,
+		doc{Title: "alpha", Rank: 1},
+		doc{Title: "beta", Rank: 2, DueAt: &time.Time{}},
+		doc{Title: "gamma", Rank: 3},
+	)
+
+	tests := []struct {
+		name   string
+		filter database.Filter
+		want   []uint
+	}{
+		{"eq", database.Filter{Field: "Rank", Value: 2}, []uint{2}},
+		{"eq by column", database.Filter{Field: "rank", Operator: database.OpEq, Value: 2}, []uint{2}},
+		{"ne", database.Filter{Field: "rank", Operator: database.OpNe, Value: 2}, []uint{1, 3}},
+		{"gt", database.Filter{Field: "rank", Operator: database.OpGt, Value: 1}, []uint{2, 3}},
+		{"gte", database.Filter{Field: "rank", Operator: database.OpGte, Value: 2}, []uint{2, 3}},
+		{"lt", database.Filter{Field: "rank", Operator: database.OpLt, Value: 3}, []uint{1, 2}},
+		{"lte", database.Filter{Field: "rank", Operator: database.OpLte, Value: 1}, []uint{1}},
+		{"like", database.Filter{Field: "title", Operator: database.OpLike, Value: "%ta"}, []uint{2}},
+		{"in", database.Filter{Field: "rank", Operator: database.OpIn, Value: []int{1, 3}}, []uint{1, 3}},
+		{"is null", database.Filter{Field: "due_at", Operator: database.OpIsNull}, []uint{1, 3}},
+		{"not null", database.Filter{Field: "due_at", Operator: database.OpNotNull}, []uint{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.List(context.Background(), database.ListOptions{Filters: []database.Filter{tt.filter}})
+			if err != nil {
+				t.Fatalf("List = %v", err)
+			}
+			if got := ids(page.Items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	_, err := repo.List(context.Background(), database.ListOptions{Filters: []database.Filter{{Field: "missing", Value: 1}}})
+	if !errors.Is(err, database.ErrUnknownField) {
+		t.Errorf("List with unknown field = %v, want ErrUnknownField", err)
+	}
+}
+
+func TestRepositoryListOffset(t *testing.T) {
+	ctx := context.Background()
+	repo := newDocRepository(t,
+		doc{Title: "a", Rank: 3},
+		doc{Title: "b", Rank: 1},
+		doc{Title: "c", Rank: 2},
+		doc{Title: "d", Rank: 1},
+	)
+
+	opts := database.ListOptions{Sort: []database.Sort{{Field: "rank"}}, Limit: 3, Count: true}
+	page, err := repo.List(ctx, opts)
+	if err != nil {
+		t.Fatalf("List = %v", err)
+	}
+	if page.Total != 4 || !reflect.DeepEqual(ids(page.Items), []uint{2, 4, 3}) {
+		t.Fatalf("first page = %v (total %d)", ids(page.Items), page.Total)
+	}
+
+	opts.Offset = 3
+	page, err = repo.List(ctx, opts)
+	if err != nil || !reflect.DeepEqual(ids(page.Items), []uint{1}) || page.NextCursor != "" {
+		t.Fatalf("second page = %v, cursor %q, %v", ids(page.Items), page.NextCursor, err)
+	}
+}
+
+func TestRepositoryListCursor(t *testing.T) {
+	day := func(n int) *time.Time {
+		d := time.Date(2026, 1, n, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	repo := newDocRepository(t,
+		doc{Title: "1", Rank: 2, DueAt: day(3)},
+		doc{Title: "2", Rank: 1},
+		doc{Title: "3", Rank: 2, DueAt: day(1)},
+		doc{Title: "4", Rank: 1},
+		doc{Title: "5", Rank: 3, DueAt: day(3)},
+		doc{Title: "6", Rank: 2},
+	)
+
+	tests := []struct {
+		name string
+		sort []database.Sort
+		want []uint
+	}{
+		{"primary key", nil, []uint{1, 2, 3, 4, 5, 6}},
+		{"primary key desc", []database.Sort{{Field: "id", Desc: true}}, []uint{6, 5, 4, 3, 2, 1}},
+		{"ties asc", []database.Sort{{Field: "rank"}}, []uint{2, 4, 1, 3, 6, 5}},
+		{"ties desc", []database.Sort{{Field: "rank", Desc: true}}, []uint{5, 6, 3, 1, 4, 2}},
+		{"nulls asc", []database.Sort{{Field: "due_at"}}, []uint{3, 1, 5, 2, 4, 6}},
+		{"nulls desc", []database.Sort{{Field: "due_at", Desc: true}}, []uint{6, 4, 2, 5, 1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []uint
+			opts := database.ListOptions{Sort: tt.sort, Limit: 2}
+			for pages := 0; ; pages++ {
+				if pages > len(tt.want) {
+					t.Fatalf("pagination did not end, got %v", got)
+				}
+				page, err := repo.List(context.Background(), opts)
+				if err != nil {
+					t.Fatalf("List = %v", err)
+				}
+				got = append(got, ids(page.Items)...)
+				if page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepositoryListInvalidCursor(t *testing.T) {
+	ctx := context.Background()
+	repo := newDocRepository(t, doc{Title: "a"}, doc{Title: "b"}, doc{Title: "c"})
+
+	page, err := repo.List(ctx, database.ListOptions{Sort: []database.Sort{{Field: "rank"}}, Limit: 1})
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("List = %+v, %v", page, err)
+	}
+
+	tests := []struct {
+		name string
+		opts database.ListOptions
+	}{
+		{"garbage", database.ListOptions{Cursor: "not a cursor"}},
+		{"other sort", database.ListOptions{Cursor: page.NextCursor, Sort: []database.Sort{{Field: "title"}}}},
+		{"two sort fields", database.ListOptions{Cursor: page.NextCursor, Sort: []database.Sort{{Field: "rank"}, {Field: "title"}}}},
+		{"with offset", database.ListOptions{Cursor: page.NextCursor, Sort: []database.Sort{{Field: "rank"}}, Offset: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repo.List(ctx, tt.opts); !errors.Is(err, database.ErrInvalidCursor) {
+				t.Errorf("List = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"example.com/config"
+	appLogger "example.com/utils"
+)
+
+// pingTimeout bounds a single connection attempt
+const pingTimeout = 5 * time.Second
+
+// waitForConnection pings the database until it answers, backing off
+// exponentially with jitter between attempts. It gives up after RetryMaxWait,
+// except with LazyConnect where there is no caller left to fail.
+// Every attempt is logged to log when it is not nil.
+func (d *DbInstance) waitForConnection(ctx context.Context, cfg config.DatabaseConfig, log *appLogger.Logger) error {
+	start := time.Now()
+	deadline := start.Add(cfg.RetryMaxWait)
+	interval := cfg.RetryInitial
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := d.Ping(pingCtx)
+		cancel()
+
+		if err == nil {
+			if log != nil {
+				log.Info("Connected to the database", map[string]interface{}{
+					"driver":     cfg.Driver,
+					"host":       cfg.Host,
+					"name":       cfg.Name,
+					"attempt":    attempt,
+					"elapsed_ms": time.Since(start).Milliseconds(),
+				})
+			}
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if cfg.LazyConnect {
+			remaining = interval
+		} else if remaining <= 0 {
+			return fmt.Errorf("database unavailable after %d attempt(s): %w", attempt, err)
+		}
+
+		// Sleep a random duration in [interval/2, interval] so that replicas
+		// restarting together do not retry in lockstep
+		delay := interval/2 + rand.N(interval/2+1)
+		if delay > remaining {
+			delay = remaining
+		}
+
+		if log != nil {
+			log.Warn("Database connection failed, retrying", map[string]interface{}{
+				"attempt":     attempt,
+				"error":       err.Error(),
+				"retry_in_ms": delay.Milliseconds(),
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		interval *= 2
+		if cfg.RetryMaxInterval > 0 && interval > cfg.RetryMaxInterval {
+			interval = cfg.RetryMaxInterval
+		}
+	}
+}
+
+// connectInBackground keeps retrying until the database is reachable or
+// the instance is closed
+func (d *DbInstance) connectInBackground(cfg config.DatabaseConfig, log *appLogger.Logger) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancelConnect = cancel
+
+	go func() {
+		defer cancel()
+
+		// Lazy connects retry until Close cancels ctx, so the error is moot
+		_ = d.waitForConnection(ctx, cfg, log)
+	}()
+}
@@ -0,0 +1,281 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"time"
+
+	logger "example.com/utils"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSeedingDisabled is returned when seeding is attempted in release mode
+var ErrSeedingDisabled = errors.New("seeding is disabled in release mode")
+
+// Seed is a named set of sample data. Seeds run once and are recorded in the
+// schema_seeds table, so running them again is a no-op unless forced.
+type Seed struct {
+	Name string
+	Run  func(ctx context.Context, tx *gorm.DB) error
+}
+
+// SeedRecord is a row of the schema_seeds tracking table
+type SeedRecord struct {
+	Name     string    `gorm:"primaryKey;size:255"`
+	SeededAt time.Time `gorm:"not null"`
+}
+
+// TableName overrides the gorm table name
+func (SeedRecord) TableName() string {
+	return "schema_seeds"
+}
+
+// SeedStatus reports whether a known seed has been run
+type SeedStatus struct {
+	Name     string     `json:"name"`
+	Seeded   bool       `json:"seeded"`
+	SeededAt *time.Time `json:"seeded_at,omitempty"`
+}
+
+// SeederConfig holds configuration for the Seeder
+type SeederConfig struct {
+	Logger *logger.Logger // Optional
+	Mode   string         // Server mode; seeding is refused in release
+	Force  bool           // Run seeds again even if they are recorded
+}
+
+// Seeder runs seeds in registration order, each in its own transaction
+type Seeder struct {
+	db     *gorm.DB
+	config SeederConfig
+	seeds  []Seed
+}
+
+// NewSeeder creates a Seeder for the given seeds. It fails with
+// ErrSeedingDisabled in release mode so sample data never reaches production.
+func NewSeeder(db *gorm.DB, config SeederConfig, seeds ...Seed) (*Seeder, error) {
+	if config.Mode == "release" {
+		return nil, ErrSeedingDisabled
+	}
+
+	names := make(map[string]struct{}, len(seeds))
+	for _, seed := range seeds {
+		if seed.Name == "" {
+			return nil, fmt.Errorf("seed has no name")
+		}
+		if seed.Run == nil {
+			return nil, fmt.Errorf("seed %q has no run function", seed.Name)
+		}
+		if _, ok := names[seed.Name]; ok {
+			return nil, fmt.Errorf("duplicate seed %q", seed.Name)
+		}
+		names[seed.Name] = struct{}{}
+	}
+
+	// Seeds must see the records they wrote on the previous run
+	db, err := primaryOnly(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Seeder{
+		db:     db,
+		config: config,
+		seeds:  seeds,
+	}, nil
+}
+
+// Run runs the named seeds, or all of them if no names are given, skipping
+// those already recorded. It returns how many seeds were run.
+func (s *Seeder) Run(ctx context.Context, names ...string) (int, error) {
+	selected, err := s.selectSeeds(names)
+	if err != nil {
+		return 0, err
+	}
+
+	// Seed data is not scoped to a request's tenant
+	ctx = WithoutTenant(ctx)
+
+	conn := s.db.WithContext(ctx)
+	if err := s.ensureTable(conn); err != nil {
+		return 0, err
+	}
+
+	done, err := s.seededNames(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	ran := 0
+	for _, seed := range selected {
+		if _, ok := done[seed.Name]; ok && !s.config.Force {
+			continue
+		}
+
+		if err := s.apply(ctx, conn, seed); err != nil {
+			return ran, err
+		}
+		ran++
+	}
+	return ran, nil
+}
+
+// Status lists every known seed and whether it has been run
+func (s *Seeder) Status(ctx context.Context) ([]SeedStatus, error) {
+	conn := s.db.WithContext(ctx)
+	if err := s.ensureTable(conn); err != nil {
+		return nil, err
+	}
+
+	done, err := s.seededNames(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]SeedStatus, 0, len(s.seeds))
+	for _, seed := range s.seeds {
+		status := SeedStatus{Name: seed.Name}
+		if record, ok := done[seed.Name]; ok {
+			seededAt := record.SeededAt
+			status.Seeded = true
+			status.SeededAt = &seededAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// selectSeeds returns the named seeds in registration order
+func (s *Seeder) selectSeeds(names []string) ([]Seed, error) {
+	if len(names) == 0 {
+		return s.seeds, nil
+	}
+
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+
+	selected := make([]Seed, 0, len(names))
+	for _, seed := range s.seeds {
+		if _, ok := wanted[seed.Name]; ok {
+			selected = append(selected, seed)
+			delete(wanted, seed.Name)
+		}
+	}
+	for name := range wanted {
+		return nil, fmt.Errorf("unknown seed %q", name)
+	}
+	return selected, nil
+}
+
+// apply runs one seed and records it in a single transaction
+func (s *Seeder) apply(ctx context.Context, conn *gorm.DB, seed Seed) error {
+	if s.config.Logger != nil {
+		s.config.Logger.Info("Running seed", map[string]interface{}{
+			"name": seed.Name,
+		})
+	}
+	start := time.Now()
+
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := seed.Run(ctx, tx); err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&SeedRecord{
+			Name:     seed.Name,
+			SeededAt: time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("seed %s failed: %w", seed.Name, err)
+	}
+
+	if s.config.Logger != nil {
+		s.config.Logger.Info("Seed completed", map[string]interface{}{
+			"name":        seed.Name,
+			"duration_ms": time.Since(start).Milliseconds(),
+		})
+	}
+	return nil
+}
+
+// ensureTable creates the schema_seeds table if it does not exist
+func (s *Seeder) ensureTable(conn *gorm.DB) error {
+	if conn.Migrator().HasTable(&SeedRecord{}) {
+		return nil
+	}
+	if err := conn.Migrator().CreateTable(&SeedRecord{}); err != nil {
+		return fmt.Errorf("failed to create schema_seeds table: %w", err)
+	}
+	return nil
+}
+
+// seededNames returns the recorded seeds keyed by name
+func (s *Seeder) seededNames(conn *gorm.DB) (map[string]SeedRecord, error) {
+	var records []SeedRecord
+	if err := conn.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_seeds: %w", err)
+	}
+
+	seeded := make(map[string]SeedRecord, len(records))
+	for _, record := range records {
+		seeded[record.Name] = record
+	}
+	return seeded, nil
+}
+
+// FixtureSeed returns a seed that inserts the records of a YAML or JSON
+// fixture file. Rows that already exist are left untouched, so a forced
+// re-run does not fail on duplicate keys.
+func FixtureSeed[T any](name string, fsys fs.FS, file string) Seed {
+	return Seed{
+		Name: name,
+		Run: func(ctx context.Context, tx *gorm.DB) error {
+			records, err := LoadFixture[T](fsys, file)
+			if err != nil {
+				return err
+			}
+			if len(records) == 0 {
+				return nil
+			}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&records).Error
+		},
+	}
+}
+
+// LoadFixture decodes a list of records from a .yaml, .yml or .json file.
+// Keys are matched against the json tags of T in both formats.
+func LoadFixture[T any](fsys fs.FS, file string) ([]T, error) {
+	content, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	switch path.Ext(file) {
+	case ".json":
+	case ".yaml", ".yml":
+		// Round-trip through JSON so one set of struct tags serves both formats
+		var raw []map[string]interface{}
+		if err := yaml.Unmarshal(content, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", file, err)
+		}
+		if content, err = json.Marshal(raw); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", file, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported fixture format %q", file)
+	}
+
+	var records []T
+	if err := json.Unmarshal(content, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode fixture %s: %w", file, err)
+	}
+	return records, nil
+}
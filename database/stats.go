@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	appLogger "example.com/utils"
+)
+
+// PoolStats holds the connection pool statistics of a single pool
+type PoolStats struct {
+	Pool string // primary, or replica_<n>
+	sql.DBStats
+}
+
+// Stats returns the pool statistics of the primary and every replica
+func (d *DbInstance) Stats() ([]PoolStats, error) {
+	sqlDB, err := d.Db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]PoolStats, 0, 1+len(d.replicas))
+	stats = append(stats, PoolStats{Pool: "primary", DBStats: sqlDB.Stats()})
+	for i, replica := range d.replicas {
+		stats = append(stats, PoolStats{Pool: fmt.Sprintf("replica_%d", i), DBStats: replica.Stats()})
+	}
+	return stats, nil
+}
+
+// ReportStats logs the pool statistics every interval until ctx is done.
+// A non-positive interval disables reporting.
+func (d *DbInstance) ReportStats(ctx context.Context, interval time.Duration, log *appLogger.Logger) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats, err := d.Stats()
+		if err != nil {
+			log.Warn("Failed to read database pool stats", map[string]interface{}{
+				"error": err.Error(),
+			})
+			continue
+		}
+
+		for _, s := range stats {
+			log.Info("Database pool stats", map[string]interface{}{
+				"pool":                 s.Pool,
+				"max_open":             s.MaxOpenConnections,
+				"open":                 s.OpenConnections,
+				"in_use":               s.InUse,
+				"idle":                 s.Idle,
+				"wait_count":           s.WaitCount,
+				"wait_duration_ms":     s.WaitDuration.Milliseconds(),
+				"max_idle_closed":      s.MaxIdleClosed,
+				"max_idle_time_closed": s.MaxIdleTimeClosed,
+				"max_lifetime_closed":  s.MaxLifetimeClosed,
+			})
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TenantColumn is the column that marks a model as tenant-scoped. Models
+// with a matching field are filtered and stamped automatically.
+const TenantColumn = "tenant_id"
+
+// ErrMissingTenant is returned when a tenant-scoped model is used with a
+// context that carries neither a tenant nor WithoutTenant
+var ErrMissingTenant = errors.New("no tenant in context")
+
+// tenantKey is the context key holding the current tenant ID
+type tenantKey struct{}
+
+// skipTenantKey is the context key disabling tenant scoping
+type skipTenantKey struct{}
+
+// WithTenant returns a copy of ctx scoped to the given tenant
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant ID carried by ctx, if any
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+// WithoutTenant returns a copy of ctx that bypasses tenant scoping, for
+// administrative operations across all tenants. Use it sparingly.
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipTenantKey{}, true)
+}
+
+// registerTenantCallbacks scopes every query, update and delete of a
+// tenant-scoped model to the tenant in the statement context and sets the
+// tenant on inserts. Without a tenant the statement fails rather than
+// touching every tenant's rows. Updates never change the tenant column and
+// upserts only overwrite rows of the same tenant. Raw SQL is not rewritten.
+func registerTenantCallbacks(db *gorm.DB) error {
+	callbacks := db.Callback()
+
+	if err := callbacks.Create().Before("gorm:create").Register("tenant:create", stampTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:query", scopeTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:update", scopeTenantUpdate); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenant:delete", scopeTenant); err != nil {
+		return err
+	}
+	return callbacks.Row().Before("gorm:row").Register("tenant:row", scopeTenant)
+}
+
+// statementTenant returns the tenant for a statement on a tenant-scoped
+// model. scoped is false when the model has no tenant column or scoping is
+// bypassed; a scoped statement without a tenant gets ErrMissingTenant.
+func statementTenant(db *gorm.DB) (tenantID string, scoped bool) {
+	if db.Statement.Schema == nil || db.Statement.Schema.LookUpField(TenantColumn) == nil {
+		return "", false
+	}
+
+	ctx := db.Statement.Context
+	if skip, _ := ctx.Value(skipTenantKey{}).(bool); skip {
+		return "", false
+	}
+
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		db.AddError(fmt.Errorf("%s: %w", db.Statement.Schema.Table, ErrMissingTenant))
+		return "", false
+	}
+	return tenantID, true
+}
+
+// scopeTenant adds a tenant_id condition to the statement
+func scopeTenant(db *gorm.DB) {
+	if db.Error != nil || db.Statement.SQL.Len() > 0 {
+		return
+	}
+
+	tenantID, scoped := statementTenant(db)
+	if !scoped {
+		return
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: TenantColumn}, Value: tenantID},
+	}})
+}
+
+// scopeTenantUpdate scopes an update like scopeTenant and keeps it from
+// writing the tenant column, even with Select("*"), so that rows cannot be
+// moved to another tenant
+func scopeTenantUpdate(db *gorm.DB) {
+	scopeTenant(db)
+	if db.Error != nil || db.Statement.SQL.Len() > 0 {
+		return
+	}
+
+	if _, scoped := statementTenant(db); scoped {
+		db.Statement.Omits = append(db.Statement.Omits, TenantColumn)
+	}
+}
+
+// stampTenant sets the tenant on every record being inserted, refusing
+// records that already belong to another tenant
+func stampTenant(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+
+	tenantID, scoped := statementTenant(db)
+	if !scoped {
+		return
+	}
+
+	field := db.Statement.Schema.LookUpField(TenantColumn)
+	ctx := db.Statement.Context
+
+	stamp := func(record reflect.Value) {
+		value, isZero := field.ValueOf(ctx, record)
+		if !isZero && fmt.Sprint(value) != tenantID {
+			db.AddError(fmt.Errorf("%s: record belongs to another tenant", db.Statement.Schema.Table))
+			return
+		}
+		if err := field.Set(ctx, record, tenantID); err != nil {
+			db.AddError(err)
+		}
+	}
+
+	switch rv := reflect.Indirect(db.Statement.ReflectValue); rv.Kind() {
+	case reflect.Struct:
+		stamp(rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			stamp(reflect.Indirect(rv.Index(i)))
+		}
+	default:
+		db.AddError(fmt.Errorf("%s: tenant-scoped records must be created from structs", db.Statement.Schema.Table))
+	}
+
+	scopeUpsert(db, tenantID)
+}
+
+// scopeUpsert restricts ON CONFLICT DO UPDATE, which Save falls back to for
+// records it could not update, to rows of the tenant. Otherwise an insert
+// conflicting with another tenant's row would overwrite it.
+func scopeUpsert(db *gorm.DB, tenantID string) {
+	c, ok := db.Statement.Clauses[clause.OnConflict{}.Name()]
+	if !ok {
+		return
+	}
+	onConflict, ok := c.Expression.(clause.OnConflict)
+	if !ok || onConflict.DoNothing {
+		return
+	}
+
+	switch db.Dialector.Name() {
+	case "postgres", "sqlite":
+		onConflict.Where.Exprs = append(onConflict.Where.Exprs,
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: TenantColumn}, Value: tenantID})
+		db.Statement.AddClause(onConflict)
+	default:
+		// MySQL's ON DUPLICATE KEY UPDATE cannot be made conditional
+		db.AddError(fmt.Errorf("%s: upserts that update rows are not supported on tenant-scoped models", db.Statement.Schema.Table))
+	}
+}
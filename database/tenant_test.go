@@ -0,0 +1,200 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"example.com/database"
+	"example.com/database/dbtest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// note is a tenant-scoped model
+type note struct {
+	ID       uint
+	TenantID string `gorm:"size:64;not null;index"`
+	Title    string
+}
+
+// newTenantDB returns a database holding one note for each of tenants a and b
+func newTenantDB(t *testing.T) *database.DbInstance {
+	t.Helper()
+
+	db := dbtest.New(t, dbtest.Options{
+		Migrations: []database.Migration{{
+			Version: 1,
+			Name:    "create_notes",
+			Up:      func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&note{}) },
+		}},
+	})
+
+	for _, tenant := range []string{"a", "b"} {
+		ctx := database.WithTenant(context.Background(), tenant)
+		if err := db.Conn(ctx).Create(&note{Title: "note of " + tenant}).Error; err != nil {
+			t.Fatalf("Create for tenant %s = %v", tenant, err)
+		}
+	}
+	return db
+}
+
+// allNotes returns every note, bypassing tenant scoping
+func allNotes(t *testing.T, db *database.DbInstance) []note {
+	t.Helper()
+
+	var notes []note
+	if err := db.Conn(database.WithoutTenant(context.Background())).Order("id").Find(&notes).Error; err != nil {
+		t.Fatalf("Find = %v", err)
+	}
+	return notes
+}
+
+func TestTenantCreateStampsTenant(t *testing.T) {
+	db := newTenantDB(t)
+
+	notes := allNotes(t, db)
+	if len(notes) != 2 || notes[0].TenantID != "a" || notes[1].TenantID != "b" {
+		t.Fatalf("notes = %+v", notes)
+	}
+
+	ctxA := database.WithTenant(context.Background(), "a")
+	err := db.Conn(ctxA).Create(&note{TenantID: "b", Title: "smuggled"}).Error
+	if err == nil {
+		t.Fatal("Create of another tenant's record succeeded")
+	}
+}
+
+func TestTenantQueriesAreScoped(t *testing.T) {
+	db := newTenantDB(t)
+	ctxA := database.WithTenant(context.Background(), "a")
+
+	var notes []note
+	if err := db.Conn(ctxA).Find(&notes).Error; err != nil {
+		t.Fatalf("Find = %v", err)
+	}
+	if len(notes) != 1 || notes[0].TenantID != "a" {
+		t.Fatalf("Find = %+v, want only tenant a", notes)
+	}
+
+	var count int64
+	if err := db.Conn(ctxA).Model(&note{}).Count(&count).Error; err != nil || count != 1 {
+		t.Fatalf("Count = %d, %v", count, err)
+	}
+
+	if err := db.Conn(ctxA).First(&note{}, 2).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("First of tenant b's note = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestTenantMissingTenantFails(t *testing.T) {
+	db := newTenantDB(t)
+
+	var notes []note
+	if err := db.Conn(context.Background()).Find(&notes).Error; !errors.Is(err, database.ErrMissingTenant) {
+		t.Errorf("Find without tenant = %v, want ErrMissingTenant", err)
+	}
+	if err := db.Conn(context.Background()).Create(&note{Title: "orphan"}).Error; !errors.Is(err, database.ErrMissingTenant) {
+		t.Errorf("Create without tenant = %v, want ErrMissingTenant", err)
+	}
+}
+
+func TestTenantWritesCannotReachOtherTenants(t *testing.T) {
+	ctxA := database.WithTenant(context.Background(), "a")
+
+	tests := []struct {
+		name  string
+		write func(db *gorm.DB) error
+	}{
+		{"update", func(db *gorm.DB) error {
+			return db.Model(&note{ID: 2}).Update("title", "changed by a").Error
+		}},
+		{"updates select all", func(db *gorm.DB) error {
+			return db.Model(&note{ID: 2}).Select("*").Updates(&note{ID: 2, Title: "changed by a"}).Error
+		}},
+		{"delete", func(db *gorm.DB) error {
+			return db.Delete(&note{}, 2).Error
+		}},
+		{"save", func(db *gorm.DB) error {
+			return db.Save(&note{ID: 2, Title: "changed by a"}).Error
+		}},
+		{"upsert", func(db *gorm.DB) error {
+			return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&note{ID: 2, Title: "changed by a"}).Error
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTenantDB(t)
+
+			// The write may fail or affect nothing, but must leave b's note alone
+			_ = tt.write(db.Conn(ctxA))
+
+			notes := allNotes(t, db)
+			if len(notes) != 2 || notes[1] != (note{ID: 2, TenantID: "b", Title: "note of b"}) {
+				t.Errorf("notes = %+v, want tenant b's note unchanged", notes)
+			}
+		})
+	}
+}
+
+func TestTenantUpdatesCannotMoveRows(t *testing.T) {
+	ctxA := database.WithTenant(context.Background(), "a")
+
+	tests := []struct {
+		name  string
+		write func(db *gorm.DB) error
+	}{
+		{"update column", func(db *gorm.DB) error {
+			return db.Model(&note{ID: 1}).Update("tenant_id", "b").Error
+		}},
+		{"updates map", func(db *gorm.DB) error {
+			return db.Model(&note{ID: 1}).Updates(map[string]interface{}{"tenant_id": "b", "title": "moved"}).Error
+		}},
+		{"updates select all", func(db *gorm.DB) error {
+			return db.Model(&note{ID: 1}).Select("*").Updates(&note{ID: 1, TenantID: "b", Title: "moved"}).Error
+		}},
+		{"save", func(db *gorm.DB) error {
+			return db.Save(&note{ID: 1, TenantID: "b", Title: "moved"}).Error
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTenantDB(t)
+
+			_ = tt.write(db.Conn(ctxA))
+
+			if notes := allNotes(t, db); notes[0].TenantID != "a" {
+				t.Errorf("note 1 = %+v, want it to stay with tenant a", notes[0])
+			}
+		})
+	}
+}
+
+func TestTenantUpsertWithinTenant(t *testing.T) {
+	db := newTenantDB(t)
+	ctxA := database.WithTenant(context.Background(), "a")
+
+	if err := db.Conn(ctxA).Save(&note{ID: 1, Title: "saved"}).Error; err != nil {
+		t.Fatalf("Save = %v", err)
+	}
+	if err := db.Conn(ctxA).Save(&note{ID: 3, Title: "new"}).Error; err != nil {
+		t.Fatalf("Save of new note = %v", err)
+	}
+
+	notes := allNotes(t, db)
+	if len(notes) != 3 || notes[0].Title != "saved" || notes[2] != (note{ID: 3, TenantID: "a", Title: "new"}) {
+		t.Errorf("notes = %+v", notes)
+	}
+}
+
+func TestTenantWithoutTenantBypassesScoping(t *testing.T) {
+	db := newTenantDB(t)
+	ctx := database.WithoutTenant(context.Background())
+
+	if err := db.Conn(ctx).Model(&note{ID: 1}).Update("tenant_id", "b").Error; err != nil {
+		t.Fatalf("Update = %v", err)
+	}
+	if notes := allNotes(t, db); notes[0].TenantID != "b" {
+		t.Errorf("note 1 = %+v, want it moved to tenant b", notes[0])
+	}
+}
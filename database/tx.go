@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// txKey is the context key holding the active *gorm.DB transaction
+type txKey struct{}
+
+// primaryKey is the context key marking that reads must use the primary
+type primaryKey struct{}
+
+// WithTx runs fn inside a transaction. The transaction travels in the context
+// passed to fn, so repositories called with that context join it. Nested
+// calls create savepoints that roll back independently. The transaction is
+// rolled back if fn returns an error or panics; the panic is re-raised after
+// the rollback.
+func (d *DbInstance) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return d.Conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// Conn returns the transaction carried by ctx, or a new session on the pool
+// when ctx has none. Outside a transaction, reads go to a replica unless ctx
+// was marked with WithPrimary. Code that should take part in WithTx or honour
+// WithPrimary must use it instead of Db directly.
+func (d *DbInstance) Conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+
+	db := d.Db.WithContext(ctx)
+	if len(d.replicas) > 0 && UsesPrimary(ctx) {
+		db = db.Clauses(dbresolver.Write).Session(&gorm.Session{})
+	}
+	return db
+}
+
+// WithPrimary returns a copy of ctx whose reads go to the primary, e.g. to
+// read data just written before it has reached the replicas
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsesPrimary reports whether ctx was marked with WithPrimary
+func UsesPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// InTx reports whether ctx carries a transaction started by WithTx
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*gorm.DB)
+	return ok
+}
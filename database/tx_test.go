@@ -0,0 +1,192 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"example.com/database"
+	"example.com/database/dbtest"
+	"gorm.io/gorm"
+)
+
+// newTxTest returns a fresh database holding docs and a repository on it
+func newTxTest(t *testing.T) (*database.DbInstance, *database.Repository[doc]) {
+	t.Helper()
+
+	db := dbtest.New(t, dbtest.Options{
+		Migrations: []database.Migration{{
+			Version: 1,
+			Name:    "create_docs",
+			Up:      func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&doc{}) },
+		}},
+	})
+	return db, database.NewRepository[doc](db)
+}
+
+// titles returns the titles of the stored docs in creation order
+func titles(t *testing.T, repo *database.Repository[doc]) []string {
+	t.Helper()
+
+	page, err := repo.List(context.Background(), database.ListOptions{})
+	if err != nil {
+		t.Fatalf("List = %v", err)
+	}
+	out := []string{}
+	for _, d := range page.Items {
+		out = append(out, d.Title)
+	}
+	return out
+}
+
+// create stores a doc titled title, failing the test on error
+func create(t *testing.T, ctx context.Context, repo *database.Repository[doc], title string) {
+	t.Helper()
+	if err := repo.Create(ctx, &doc{Title: title}); err != nil {
+		t.Fatalf("Create(%q) = %v", title, err)
+	}
+}
+
+func TestWithTxCommits(t *testing.T) {
+	db, repo := newTxTest(t)
+
+	err := db.WithTx(context.Background(), func(ctx context.Context) error {
+		if !database.InTx(ctx) {
+			t.Error("InTx = false inside WithTx")
+		}
+		create(t, ctx, repo, "a")
+		create(t, ctx, repo, "b")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx = %v", err)
+	}
+
+	if got := titles(t, repo); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("titles = %v, want [a b]", got)
+	}
+	if database.InTx(context.Background()) {
+		t.Error("InTx = true outside WithTx")
+	}
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	db, repo := newTxTest(t)
+	failure := errors.New("failure")
+
+	err := db.WithTx(context.Background(), func(ctx context.Context) error {
+		create(t, ctx, repo, "a")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithTx = %v, want the error of fn", err)
+	}
+
+	if got := titles(t, repo); len(got) != 0 {
+		t.Errorf("titles = %v, want none", got)
+	}
+}
+
+func TestWithTxRollsBackOnPanic(t *testing.T) {
+	db, repo := newTxTest(t)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, want the panic of fn", r)
+			}
+		}()
+		db.WithTx(context.Background(), func(ctx context.Context) error {
+			create(t, ctx, repo, "a")
+			panic("boom")
+		})
+		t.Error("WithTx returned instead of re-raising the panic")
+	}()
+
+	if got := titles(t, repo); len(got) != 0 {
+		t.Errorf("titles = %v, want none", got)
+	}
+	// The connection went back to the pool usable
+	create(t, context.Background(), repo, "b")
+}
+
+func TestWithTxNestedRollsBackSavepoint(t *testing.T) {
+	db, repo := newTxTest(t)
+	failure := errors.New("failure")
+
+	err := db.WithTx(context.Background(), func(ctx context.Context) error {
+		create(t, ctx, repo, "outer")
+
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			create(t, ctx, repo, "inner")
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Errorf("nested WithTx = %v, want the error of fn", err)
+		}
+
+		create(t, ctx, repo, "after")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx = %v", err)
+	}
+
+	if got := titles(t, repo); !reflect.DeepEqual(got, []string{"outer", "after"}) {
+		t.Errorf("titles = %v, want [outer after]", got)
+	}
+}
+
+func TestWithTxNestedRollsBackWithOuter(t *testing.T) {
+	db, repo := newTxTest(t)
+	failure := errors.New("failure")
+
+	err := db.WithTx(context.Background(), func(ctx context.Context) error {
+		create(t, ctx, repo, "outer")
+
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			create(t, ctx, repo, "inner")
+			return nil
+		})
+		if err != nil {
+			t.Errorf("nested WithTx = %v", err)
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithTx = %v, want the error of fn", err)
+	}
+
+	if got := titles(t, repo); len(got) != 0 {
+		t.Errorf("titles = %v, want none", got)
+	}
+}
+
+func TestWithTxNestedPanic(t *testing.T) {
+	db, repo := newTxTest(t)
+
+	err := db.WithTx(context.Background(), func(ctx context.Context) error {
+		create(t, ctx, repo, "outer")
+
+		func() {
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Errorf("recovered %v, want the panic of fn", r)
+				}
+			}()
+			db.WithTx(ctx, func(ctx context.Context) error {
+				create(t, ctx, repo, "inner")
+				panic("boom")
+			})
+		}()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx = %v", err)
+	}
+
+	if got := titles(t, repo); !reflect.DeepEqual(got, []string{"outer"}) {
+		t.Errorf("titles = %v, want [outer]", got)
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"example.com/models"
+	"gorm.io/gorm"
+)
+
+// UserTokenStore persists the single-use tokens emailed to users
+type UserTokenStore struct {
+	db *DbInstance
+}
+
+// NewUserTokenStore creates a UserTokenStore
+func NewUserTokenStore(db *DbInstance) *UserTokenStore {
+	return &UserTokenStore{db: db}
+}
+
+// conn uses the primary: a link can be followed within seconds of the email
+// being sent, before a replica has the token, and a consumed token must not
+// be found again on a lagging replica
+func (s *UserTokenStore) conn(ctx context.Context) *gorm.DB {
+	return s.db.Conn(WithPrimary(ctx))
+}
+
+// Create stores a new token
+func (s *UserTokenStore) Create(ctx context.Context, token *models.UserToken) error {
+	return s.conn(ctx).Create(token).Error
+}
+
+// Consume marks the unused, unexpired token with the given purpose and hash
+// as used and returns it. Only one of several concurrent calls succeeds; the
+// others get ErrNotFound, as for unknown tokens.
+func (s *UserTokenStore) Consume(ctx context.Context, purpose, hash string, at time.Time) (*models.UserToken, error) {
+	var token models.UserToken
+	err := s.conn(ctx).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", hash, purpose, at).
+		Take(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	result := s.conn(ctx).Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", at)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return nil, ErrNotFound
+	}
+
+	token.UsedAt = &at
+	return &token, nil
+}
+
+// Invalidate marks the unused tokens of a user as used. Without purposes,
+// tokens of every purpose are invalidated.
+func (s *UserTokenStore) Invalidate(ctx context.Context, userID uint, at time.Time, purposes ...string) error {
+	query := s.conn(ctx).Model(&models.UserToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID)
+	if len(purposes) > 0 {
+		query = query.Where("purpose IN ?", purposes)
+	}
+	return query.Update("used_at", at).Error
+}
@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"example.com/cache"
+	"example.com/config"
+	"example.com/database"
+)
+
+// DatabaseCheck pings the database connection pool
+func DatabaseCheck(db *database.DbInstance) Check {
+	return func(ctx context.Context) error {
+		return db.Ping(ctx)
+	}
+}
+
+// CacheCheck pings the cache
+func CacheCheck(c cache.Cache) Check {
+	return func(ctx context.Context) error {
+		return c.Ping(ctx)
+	}
+}
+
+// LocalStorageCheck verifies that the local storage directory exists
+func LocalStorageCheck(path string) Check {
+	return func(ctx context.Context) error {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", path)
+		}
+		return nil
+	}
+}
+
+// SMTPCheck verifies that the SMTP server accepts TCP connections
+func SMTPCheck(cfg config.EmailConfig) Check {
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status is the state of a single dependency or of the whole service
+type Status string
+
+// Health statuses
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Check reports whether a dependency is usable; a nil error means healthy.
+// Checks must honour ctx cancellation.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check
+type Result struct {
+	Status    Status  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Optional  bool    `json:"optional,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// registration is a check plus whether it gates readiness
+type registration struct {
+	check    Check
+	optional bool
+}
+
+// Report aggregates the results of every registered check
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Registry holds the named readiness checks of the service's dependencies
+type Registry struct {
+	timeout time.Duration
+	checks  map[string]registration
+	mutex   sync.RWMutex
+}
+
+// NewRegistry creates an empty registry; each check is cancelled after timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Registry{
+		timeout: timeout,
+		checks:  make(map[string]registration),
+	}
+}
+
+// Register adds or replaces a check that must pass for the service to be ready
+func (r *Registry) Register(name string, check Check) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.checks[name] = registration{check: check}
+}
+
+// RegisterOptional adds or replaces a check whose result is reported but
+// does not affect readiness, for dependencies the service can run without
+func (r *Registry) RegisterOptional(name string, check Check) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.checks[name] = registration{check: check, optional: true}
+}
+
+// Names returns the registered check names in sorted order
+func (r *Registry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check runs every registered check concurrently. The report is up only if
+// every non-optional check passed.
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mutex.RLock()
+	checks := make(map[string]registration, len(r.checks))
+	for name, reg := range r.checks {
+		checks[name] = reg
+	}
+	r.mutex.RUnlock()
+
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]Result, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for name, reg := range checks {
+		wg.Add(1)
+		go func(name string, reg registration) {
+			defer wg.Done()
+			result := r.run(ctx, reg.check)
+			result.Optional = reg.optional
+
+			mutex.Lock()
+			report.Checks[name] = result
+			if result.Status != StatusUp && !reg.optional {
+				report.Status = StatusDown
+			}
+			mutex.Unlock()
+		}(name, reg)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run executes one check with the registry timeout, converting panics to failures
+func (r *Registry) run(ctx context.Context, check Check) (result Result) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result = Result{Status: StatusDown, Error: fmt.Sprintf("check panicked: %v", recovered)}
+		}
+		result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	if err := check(ctx); err != nil {
+		return Result{Status: StatusDown, Error: err.Error()}
+	}
+	return Result{Status: StatusUp}
+}
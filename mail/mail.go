@@ -0,0 +1,214 @@
+// Package mail renders and sends the emails the application sends to users
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/config"
+	logger "example.com/utils"
+)
+
+// dialTimeout bounds connecting to the SMTP server
+const dialTimeout = 10 * time.Second
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns an SMTPMailer for the email configuration, or a LogMailer when
+// no SMTP host is configured. Outside debug mode the LogMailer leaves bodies
+// out of the log.
+func New(cfg config.EmailConfig, mode string, log *logger.Logger) Mailer {
+	if cfg.SMTPHost == "" {
+		return &LogMailer{logger: log, logBody: mode == "debug"}
+	}
+	return NewSMTPMailer(cfg)
+}
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	config config.EmailConfig
+	from   mail.Address
+}
+
+// NewSMTPMailer creates an SMTPMailer
+func NewSMTPMailer(cfg config.EmailConfig) *SMTPMailer {
+	return &SMTPMailer{
+		config: cfg,
+		from:   mail.Address{Name: cfg.FromName, Address: cfg.FromEmail},
+	}
+}
+
+// Send delivers msg. Port 465 uses implicit TLS; on other ports the
+// connection is upgraded with STARTTLS, and servers that do not offer it are
+// refused unless SMTPInsecure is set.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	data, err := m.compose(to, msg)
+	if err != nil {
+		return err
+	}
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Deadlines on the connection are not exposed by smtp.Client, so a
+	// cancelled ctx closes the client to unblock it
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	if err := m.deliver(client, to.Address, data); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// dial connects to the server and authenticates
+func (m *SMTPMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.config.SMTPHost, strconv.Itoa(m.config.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: m.config.SMTPHost}
+
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if m.config.SMTPPort == 465 {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(dialCtx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(dialCtx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+
+	client, err := smtp.NewClient(conn, m.config.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+
+	if m.config.SMTPPort != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("failed to start TLS: %w", err)
+			}
+		} else if !m.config.SMTPInsecure {
+			client.Close()
+			return nil, fmt.Errorf("SMTP server does not offer STARTTLS")
+		}
+	}
+
+	// PlainAuth refuses to send credentials over unencrypted connections
+	// except to localhost
+	if m.config.SMTPUsername != "" {
+		auth := smtp.PlainAuth("", m.config.SMTPUsername, m.config.SMTPPassword, m.config.SMTPHost)
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// deliver sends one message over an open session
+func (m *SMTPMailer) deliver(client *smtp.Client, to string, data []byte) error {
+	if err := client.Mail(m.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// compose formats msg with its headers
+func (m *SMTPMailer) compose(to *mail.Address, msg Message) ([]byte, error) {
+	// Header values must not smuggle in further headers
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, fmt.Errorf("subject must be a single line")
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + m.from.String() + "\r\n")
+	b.WriteString("To: " + to.String() + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return []byte(b.String()), nil
+}
+
+// LogMailer logs emails instead of sending them, for development without an
+// SMTP server
+type LogMailer struct {
+	logger *logger.Logger
+	// logBody logs the body at debug level. Bodies carry live reset and
+	// verification links, so it is only set in debug mode.
+	logBody bool
+}
+
+// Send logs msg
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if !m.logBody {
+		m.logger.Warn("Email not sent, no SMTP host configured", map[string]interface{}{
+			"to":      msg.To,
+			"subject": msg.Subject,
+		})
+		return nil
+	}
+	m.logger.Debug("Email not sent, no SMTP host configured", map[string]interface{}{
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"body":    msg.Body,
+	})
+	return nil
+}
@@ -0,0 +1,124 @@
+package mail_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"example.com/config"
+	"example.com/mail"
+)
+
+// plainSMTPServer accepts SMTP sessions without offering STARTTLS and
+// records the messages it receives
+type plainSMTPServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	messages []string
+}
+
+func newPlainSMTPServer(t *testing.T) *plainSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen = %v", err)
+	}
+	s := &plainSMTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *plainSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch verb := strings.ToUpper(strings.Fields(line + " ")[0]); verb {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.mutex.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mutex.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *plainSMTPServer) config(insecure bool) config.EmailConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return config.EmailConfig{
+		SMTPHost:     host,
+		SMTPPort:     portNumber,
+		SMTPInsecure: insecure,
+		FromEmail:    "noreply@example.com",
+	}
+}
+
+func TestSMTPMailerRequiresTLS(t *testing.T) {
+	server := newPlainSMTPServer(t)
+	msg := mail.Message{To: "ada@example.com", Subject: "Hello", Body: "Hi Ada"}
+
+	err := mail.NewSMTPMailer(server.config(false)).Send(context.Background(), msg)
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("Send = %v, want an error for the missing STARTTLS", err)
+	}
+	if len(server.messages) != 0 {
+		t.Fatalf("server received %d messages without TLS", len(server.messages))
+	}
+}
+
+func TestSMTPMailerInsecure(t *testing.T) {
+	server := newPlainSMTPServer(t)
+	msg := mail.Message{To: "ada@example.com", Subject: "Hello", Body: "Hi Ada"}
+
+	if err := mail.NewSMTPMailer(server.config(true)).Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send = %v", err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.messages) != 1 || !strings.Contains(server.messages[0], "Hi Ada") {
+		t.Fatalf("messages = %q, want the one sent", server.messages)
+	}
+}
@@ -1,14 +1,61 @@
 package main
 
 import (
-	"example.com/config"
-	"example.com/routes"
+	"errors"
+	"fmt"
+	"os"
 )
 
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// errUsage marks errors caused by invalid command line arguments
+var errUsage = errors.New("invalid usage")
+
+const usage = `Usage: %s <command> [arguments]
+
+Commands:
+  serve                          Start the HTTP server (default)
+  migrate up [--dry-run]         Apply all pending migrations
+  migrate down [--dry-run] [n]   Roll back the last n migrations (default 1)
+  migrate status                 List migrations and whether they are applied
+  migrate create <name>          Create empty up/down SQL migration files
+  seed run [--force] [name...]   Run the named seeds, or all pending seeds
+  seed status                    List seeds and whether they have run
+  config print                   Print the loaded configuration with secrets redacted
+  version                        Print the build version
+`
+
 func main() {
-	//database.ConnectDb()
+	command, args := "serve", []string(nil)
+	if len(os.Args) > 1 {
+		command, args = os.Args[1], os.Args[2:]
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "migrate":
+		err = runMigrate(args)
+	case "seed":
+		err = runSeed(args)
+	case "config":
+		err = runConfig(args)
+	case "version":
+		fmt.Println(version)
+	case "help", "-h", "--help":
+		fmt.Printf(usage, os.Args[0])
+	default:
+		err = fmt.Errorf("%w: unknown command %q", errUsage, command)
+	}
 
-	// load configs
-	appConfig, _ := config.Load()
-	routes.SetupRouter(appConfig.Logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, usage, os.Args[0])
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
 }
@@ -1,32 +1,123 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
+	"example.com/config"
 	"example.com/utils"
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(c *gin.Context) {
-	token, err := c.Cookie("token")
+// authRealm is the realm advertised in WWW-Authenticate challenges
+const authRealm = "api"
 
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-			"msg":   err,
-		})
-		c.Abort()
-		return
+// TokenExtractor returns the access token of a request, or "" if it has none
+type TokenExtractor func(c *gin.Context) string
+
+// FromAuthorizationHeader reads a token sent as "Authorization: Bearer <token>"
+func FromAuthorizationHeader() TokenExtractor {
+	return func(c *gin.Context) string {
+		scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	}
+}
+
+// FromCookie reads a token from the named cookie
+func FromCookie(name string) TokenExtractor {
+	return func(c *gin.Context) string {
+		token, err := c.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return token
+	}
+}
+
+// FromQuery reads a token from a query parameter. Browsers cannot set headers
+// on websocket handshakes, so it only applies to upgrade requests; elsewhere
+// tokens in URLs would end up in access logs.
+func FromQuery(param string) TokenExtractor {
+	return func(c *gin.Context) string {
+		if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			return ""
+		}
+		return c.Query(param)
+	}
+}
+
+// TokenExtractors builds the configured token extractors, in order
+func TokenExtractors(cfg config.JWTConfig) []TokenExtractor {
+	extractors := make([]TokenExtractor, 0, len(cfg.TokenSources))
+	for _, source := range cfg.TokenSources {
+		switch strings.TrimSpace(source) {
+		case "header":
+			extractors = append(extractors, FromAuthorizationHeader())
+		case "cookie":
+			extractors = append(extractors, FromCookie(cfg.CookieName))
+		case "query":
+			extractors = append(extractors, FromQuery(cfg.QueryParam))
+		}
+	}
+	return extractors
+}
+
+// defaultExtractors are used when AuthMiddleware is given none
+var defaultExtractors = []TokenExtractor{FromAuthorizationHeader(), FromCookie("token")}
+
+// extractToken returns the first token found by the extractors
+func extractToken(c *gin.Context, extractors []TokenExtractor) string {
+	for _, extract := range extractors {
+		if token := extract(c); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// AuthMiddleware rejects requests without a valid access token, looking for
+// it with each extractor in turn. Without extractors it checks the
+// Authorization header and then the token cookie. The caller is available to
+// handlers through GetPrincipal.
+func AuthMiddleware(tokens *utils.TokenService, extractors ...TokenExtractor) gin.HandlerFunc {
+	if len(extractors) == 0 {
+		extractors = defaultExtractors
 	}
 
-	if _, error := utils.ParseJwt(token); error != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-			"msg":   error,
-		})
-		c.Abort()
-		return
+	return func(c *gin.Context) {
+		token := extractToken(c, extractors)
+		if token == "" {
+			abortUnauthorized(c, "", "authentication required")
+			return
+		}
+
+		claims, err := tokens.Parse(token)
+		if err != nil {
+			abortUnauthorized(c, "invalid_token", err.Error())
+			return
+		}
+
+		setPrincipal(c, principalFromClaims(claims))
+		c.Next()
+	}
+}
+
+// abortUnauthorized responds 401 with a Bearer challenge as in RFC 6750.
+// The error code is omitted when the request carried no credentials.
+func abortUnauthorized(c *gin.Context, code, msg string) {
+	challenge := fmt.Sprintf("Bearer realm=%q", authRealm)
+	if code != "" {
+		challenge += fmt.Sprintf(", error=%q, error_description=%q", code, strings.ReplaceAll(msg, `"`, `'`))
 	}
+	c.Header("WWW-Authenticate", challenge)
 
-	c.Next()
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error": "Unauthorized",
+		"msg":   msg,
+	})
+	c.Abort()
 }
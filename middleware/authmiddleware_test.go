@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/config"
+	"example.com/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testJWTConfig is an HS256 configuration for signing test tokens
+var testJWTConfig = config.JWTConfig{
+	Secret:         "test-secret-that-is-at-least-32-bytes",
+	Issuer:         "test",
+	AccessTokenTTL: time.Minute,
+	Algorithm:      "HS256",
+	CookieName:     "token",
+	QueryParam:     "access_token",
+}
+
+// newTestTokens returns a TokenService for testJWTConfig
+func newTestTokens(t *testing.T) *utils.TokenService {
+	t.Helper()
+
+	tokens, err := utils.NewTokenService(testJWTConfig, nil)
+	if err != nil {
+		t.Fatalf("NewTokenService = %v", err)
+	}
+	return tokens
+}
+
+// signToken signs an access token for subject 1 with the given tenant claim
+func signToken(t *testing.T, tokens *utils.TokenService, tenantID string) string {
+	t.Helper()
+
+	token, err := tokens.Sign(&utils.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "1"},
+		TenantID:         tenantID,
+	})
+	if err != nil {
+		t.Fatalf("Sign = %v", err)
+	}
+	return token
+}
+
+// authRequest describes the credentials sent by an AuthMiddleware test
+type authRequest struct {
+	header    string // Authorization header
+	cookie    string // token cookie
+	query     string // access_token query parameter
+	websocket bool   // Send as a websocket upgrade
+}
+
+// serveAuth runs req through AuthMiddleware with the given token sources and
+// returns the response and the authenticated subject
+func serveAuth(t *testing.T, tokens *utils.TokenService, sources []string, req authRequest) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	cfg := testJWTConfig
+	cfg.TokenSources = sources
+
+	var subject string
+	r := gin.New()
+	r.Use(AuthMiddleware(tokens, TokenExtractors(cfg)...))
+	r.GET("/", func(c *gin.Context) {
+		subject = GetSubject(c)
+		c.Status(http.StatusOK)
+	})
+
+	target := "/"
+	if req.query != "" {
+		target += "?access_token=" + req.query
+	}
+	httpReq := httptest.NewRequest(http.MethodGet, target, nil)
+	if req.header != "" {
+		httpReq.Header.Set("Authorization", req.header)
+	}
+	if req.cookie != "" {
+		httpReq.AddCookie(&http.Cookie{Name: "token", Value: req.cookie})
+	}
+	if req.websocket {
+		httpReq.Header.Set("Connection", "Upgrade")
+		httpReq.Header.Set("Upgrade", "websocket")
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httpReq)
+	return w, subject
+}
+
+func TestAuthMiddlewareExtractorOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := newTestTokens(t)
+	valid := signToken(t, tokens, "")
+	all := []string{"header", "cookie", "query"}
+
+	tests := []struct {
+		name       string
+		sources    []string
+		req        authRequest
+		wantStatus int
+	}{
+		{"header", all, authRequest{header: "Bearer " + valid}, http.StatusOK},
+		{"lowercase scheme", all, authRequest{header: "bearer " + valid}, http.StatusOK},
+		{"cookie", all, authRequest{cookie: valid}, http.StatusOK},
+		{"header before cookie", all, authRequest{header: "Bearer " + valid, cookie: "invalid"}, http.StatusOK},
+		// The first token found decides; an invalid one is not skipped
+		{"invalid header shadows cookie", all, authRequest{header: "Bearer invalid", cookie: valid}, http.StatusUnauthorized},
+		{"cookie before header", []string{"cookie", "header"}, authRequest{header: "Bearer invalid", cookie: valid}, http.StatusOK},
+		{"other scheme is no token", all, authRequest{header: "Basic " + valid, cookie: valid}, http.StatusOK},
+		{"unconfigured source", []string{"header"}, authRequest{cookie: valid}, http.StatusUnauthorized},
+		{"query on websocket upgrade", all, authRequest{query: valid, websocket: true}, http.StatusOK},
+		{"query on plain request", all, authRequest{query: valid}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, subject := serveAuth(t, tokens, tt.sources, tt.req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusOK && subject != "1" {
+				t.Errorf("subject = %q, want 1", subject)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareChallenge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := newTestTokens(t)
+	sources := []string{"header"}
+
+	w, _ := serveAuth(t, tokens, sources, authRequest{})
+	if got := w.Header().Get("WWW-Authenticate"); got != `Bearer realm="api"` {
+		t.Errorf("challenge without credentials = %q", got)
+	}
+
+	w, _ = serveAuth(t, tokens, sources, authRequest{header: "Bearer invalid"})
+	if got := w.Header().Get("WWW-Authenticate"); !strings.HasPrefix(got, `Bearer realm="api", error="invalid_token", error_description=`) {
+		t.Errorf("challenge for invalid token = %q", got)
+	}
+
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "1",
+		Issuer:    testJWTConfig.Issuer,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+	}).SignedString([]byte(testJWTConfig.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, _ = serveAuth(t, tokens, sources, authRequest{header: "Bearer " + expired})
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Header().Get("WWW-Authenticate"), `error="invalid_token"`) {
+		t.Errorf("expired token = %d, %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Built-in roles, from most to least privileged
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Policy decides whether the principal may perform the request, e.g. by
+// checking that it owns the requested resource. Returning an error aborts
+// the request with 500.
+type Policy func(c *gin.Context, principal *Principal) (bool, error)
+
+// Authorizer holds role inheritance and the permissions granted to roles.
+// Define roles and grants at startup; it is not safe to modify while serving.
+type Authorizer struct {
+	inherits    map[string][]string
+	permissions map[string][]string
+}
+
+// NewAuthorizer creates an Authorizer without any roles
+func NewAuthorizer() *Authorizer {
+	return &Authorizer{
+		inherits:    make(map[string][]string),
+		permissions: make(map[string][]string),
+	}
+}
+
+// DefaultAuthorizer backs the package-level Require functions. Admin implies
+// editor, which implies viewer.
+var DefaultAuthorizer = NewAuthorizer().
+	Role(RoleViewer).
+	Role(RoleEditor, RoleViewer).
+	Role(RoleAdmin, RoleEditor)
+
+// Role defines a role that implies all permissions and roles of inherits
+func (a *Authorizer) Role(role string, inherits ...string) *Authorizer {
+	a.inherits[role] = append(a.inherits[role], inherits...)
+	return a
+}
+
+// Grant gives permissions to a role and every role inheriting it
+func (a *Authorizer) Grant(role string, permissions ...string) *Authorizer {
+	a.permissions[role] = append(a.permissions[role], permissions...)
+	return a
+}
+
+// EffectiveRoles returns the given roles and all roles they imply
+func (a *Authorizer) EffectiveRoles(roles []string) map[string]struct{} {
+	effective := make(map[string]struct{}, len(roles))
+
+	pending := append([]string(nil), roles...)
+	for len(pending) > 0 {
+		role := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		// Already visited roles also guard against inheritance cycles
+		if _, ok := effective[role]; ok {
+			continue
+		}
+		effective[role] = struct{}{}
+		pending = append(pending, a.inherits[role]...)
+	}
+	return effective
+}
+
+// HasRole reports whether the principal has role, directly or inherited
+func (a *Authorizer) HasRole(principal *Principal, role string) bool {
+	_, ok := a.EffectiveRoles(principal.Roles)[role]
+	return ok
+}
+
+// HasPermission reports whether any of the principal's effective roles grants permission
+func (a *Authorizer) HasPermission(principal *Principal, permission string) bool {
+	for role := range a.EffectiveRoles(principal.Roles) {
+		if slices.Contains(a.permissions[role], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRoles allows principals holding at least one of roles
+func (a *Authorizer) RequireRoles(roles ...string) gin.HandlerFunc {
+	return authorize(func(c *gin.Context, principal *Principal) (bool, error) {
+		effective := a.EffectiveRoles(principal.Roles)
+		for _, role := range roles {
+			if _, ok := effective[role]; ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, fmt.Sprintf("requires role %s", strings.Join(roles, " or ")))
+}
+
+// RequirePermissions allows principals whose roles grant all of permissions
+func (a *Authorizer) RequirePermissions(permissions ...string) gin.HandlerFunc {
+	return authorize(func(c *gin.Context, principal *Principal) (bool, error) {
+		for _, permission := range permissions {
+			if !a.HasPermission(principal, permission) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, fmt.Sprintf("requires permission %s", strings.Join(permissions, " and ")))
+}
+
+// RequireRoles allows principals holding at least one of roles, using DefaultAuthorizer
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return DefaultAuthorizer.RequireRoles(roles...)
+}
+
+// RequirePermissions allows principals granted all of permissions, using DefaultAuthorizer
+func RequirePermissions(permissions ...string) gin.HandlerFunc {
+	return DefaultAuthorizer.RequirePermissions(permissions...)
+}
+
+// RequireScopes allows principals whose token carries all of scopes
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return authorize(func(c *gin.Context, principal *Principal) (bool, error) {
+		for _, scope := range scopes {
+			if !slices.Contains(principal.Scopes, scope) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, fmt.Sprintf("requires scope %s", strings.Join(scopes, " and ")))
+}
+
+// RequirePolicy allows requests for which policy returns true
+func RequirePolicy(policy Policy) gin.HandlerFunc {
+	return authorize(policy, "access denied")
+}
+
+// authorize runs a check against the request's principal. It must be used
+// after AuthMiddleware; requests without a principal get 401 and requests
+// failing the check 403 with msg.
+func authorize(check Policy, msg string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			abortUnauthorized(c, "", "authentication required")
+			return
+		}
+
+		allowed, err := check(c, principal)
+		if err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Internal Server Error",
+				"msg":   "authorization check failed",
+			})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			abortForbidden(c, msg)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// abortForbidden responds 403 with the standard error body
+func abortForbidden(c *gin.Context, msg string) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "Forbidden",
+		"msg":   msg,
+	})
+	c.Abort()
+}
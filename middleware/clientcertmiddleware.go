@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"example.com/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// clientIdentityKey is the gin.Context key holding the *ClientIdentity
+const clientIdentityKey = "clientIdentity"
+
+// ClientIdentity is the principal authenticated by a verified client certificate
+type ClientIdentity struct {
+	Subject        string   `json:"subject"`
+	CommonName     string   `json:"common_name"`
+	DNSNames       []string `json:"dns_names,omitempty"`
+	EmailAddresses []string `json:"email_addresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+	SerialNumber   string   `json:"serial_number"`
+}
+
+// Name identifies the certificate: its common name, or failing that its
+// first URI, DNS name or email address. It is "" if the certificate has none.
+func (i *ClientIdentity) Name() string {
+	if i.CommonName != "" {
+		return i.CommonName
+	}
+	for _, names := range [][]string{i.URIs, i.DNSNames, i.EmailAddresses} {
+		if len(names) > 0 {
+			return names[0]
+		}
+	}
+	return ""
+}
+
+// ClientRoles returns the roles granted to a client certificate
+type ClientRoles func(identity *ClientIdentity) []string
+
+// ClientRolesFromConfig grants roles to certificates by name, from
+// "name=role" pairs; a name may appear once per role
+func ClientRolesFromConfig(pairs []string) ClientRoles {
+	roles := make(map[string][]string, len(pairs))
+	for _, pair := range pairs {
+		// URIs may contain "=", roles do not
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			continue
+		}
+		name := strings.TrimSpace(pair[:i])
+		roles[name] = append(roles[name], strings.TrimSpace(pair[i+1:]))
+	}
+
+	return func(identity *ClientIdentity) []string {
+		return roles[identity.Name()]
+	}
+}
+
+// ClientCertMiddleware exposes the identity of a verified client certificate
+// on the context. Requests without one pass through untouched.
+func ClientCertMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// VerifiedChains is only populated when the certificate was checked
+		// against the configured client CA bundle
+		if c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) > 0 {
+			cert := c.Request.TLS.VerifiedChains[0][0]
+
+			uris := make([]string, 0, len(cert.URIs))
+			for _, uri := range cert.URIs {
+				uris = append(uris, uri.String())
+			}
+
+			c.Set(clientIdentityKey, &ClientIdentity{
+				Subject:        cert.Subject.String(),
+				CommonName:     cert.Subject.CommonName,
+				DNSNames:       cert.DNSNames,
+				EmailAddresses: cert.EmailAddresses,
+				URIs:           uris,
+				SerialNumber:   cert.SerialNumber.String(),
+			})
+		}
+
+		c.Next()
+	}
+}
+
+// GetClientIdentity returns the client certificate identity, if any
+func GetClientIdentity(c *gin.Context) (*ClientIdentity, bool) {
+	if value, exists := c.Get(clientIdentityKey); exists {
+		if identity, ok := value.(*ClientIdentity); ok {
+			return identity, true
+		}
+	}
+	return nil, false
+}
+
+// RequireClientCert rejects requests without a verified client certificate
+func RequireClientCert(c *gin.Context) {
+	if _, ok := GetClientIdentity(c); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Unauthorized",
+			"msg":   "client certificate required",
+		})
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
+// AuthOrClientCertMiddleware accepts either a verified client certificate or
+// an access token checked by AuthMiddleware. Certificates get the roles
+// granted by clientRoles, which may be nil to grant none.
+func AuthOrClientCertMiddleware(tokens *utils.TokenService, clientRoles ClientRoles, extractors ...TokenExtractor) gin.HandlerFunc {
+	auth := AuthMiddleware(tokens, extractors...)
+
+	return func(c *gin.Context) {
+		if identity, ok := GetClientIdentity(c); ok {
+			if identity.Name() == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Unauthorized",
+					"msg":   "client certificate carries no name",
+				})
+				c.Abort()
+				return
+			}
+
+			setPrincipal(c, principalFromClientIdentity(identity, clientRoles))
+			c.Next()
+			return
+		}
+
+		auth(c)
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// withClientCert marks req as carrying a verified client certificate
+func withClientCert(req *http.Request, cert *x509.Certificate) {
+	cert.SerialNumber = big.NewInt(1)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func TestAuthOrClientCertMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := newTestTokens(t)
+	spiffe, _ := url.Parse("spiffe://example.com/billing")
+
+	clientRoles := ClientRolesFromConfig([]string{
+		"reports=viewer",
+		"spiffe://example.com/billing=admin",
+		"1=admin",
+	})
+
+	tests := []struct {
+		name        string
+		cert        *x509.Certificate
+		token       string
+		wantStatus  int
+		wantKind    string
+		wantSubject string
+		wantRoles   []string
+	}{
+		{"common name", &x509.Certificate{Subject: pkix.Name{CommonName: "reports"}}, "", http.StatusOK, PrincipalClientCert, "cert:reports", []string{"viewer"}},
+		{"numeric common name", &x509.Certificate{Subject: pkix.Name{CommonName: "1"}}, "", http.StatusOK, PrincipalClientCert, "cert:1", []string{"admin"}},
+		{"uri only", &x509.Certificate{URIs: []*url.URL{spiffe}}, "", http.StatusOK, PrincipalClientCert, "cert:spiffe://example.com/billing", []string{"admin"}},
+		{"dns name only", &x509.Certificate{DNSNames: []string{"worker.internal"}}, "", http.StatusOK, PrincipalClientCert, "cert:worker.internal", nil},
+		{"no name", &x509.Certificate{}, "", http.StatusUnauthorized, "", "", nil},
+		{"token", nil, signToken(t, tokens, ""), http.StatusOK, PrincipalToken, "1", nil},
+		{"nothing", nil, "", http.StatusUnauthorized, "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *Principal
+			r := gin.New()
+			r.Use(ClientCertMiddleware(), AuthOrClientCertMiddleware(tokens, clientRoles))
+			r.GET("/", func(c *gin.Context) {
+				got, _ = GetPrincipal(c)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cert != nil {
+				withClientCert(req, tt.cert)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got.Kind != tt.wantKind || got.Subject != tt.wantSubject || !reflect.DeepEqual(got.Roles, tt.wantRoles) {
+				t.Errorf("principal = %+v, want kind %q, subject %q and roles %v", got, tt.wantKind, tt.wantSubject, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestClientCertRolesPassRequireRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := newTestTokens(t)
+	clientRoles := ClientRolesFromConfig([]string{"billing=editor"})
+
+	for name, want := range map[string]int{"billing": http.StatusOK, "reports": http.StatusForbidden} {
+		t.Run(name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(ClientCertMiddleware(), AuthOrClientCertMiddleware(tokens, clientRoles), RequireRoles(RoleViewer))
+			r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			withClientCert(req, &x509.Certificate{Subject: pkix.Name{CommonName: name}})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != want {
+				t.Errorf("status = %d, want %d: %s", w.Code, want, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"example.com/database"
+	"github.com/gin-gonic/gin"
+)
+
+// primaryCookie marks a client whose reads should stay on the primary
+const primaryCookie = "db_primary"
+
+// StickyPrimaryMiddleware routes the reads of a writing request to the
+// primary database. The client is then kept on the primary for window via a
+// short-lived cookie, so it reads its own writes despite replica lag.
+func StickyPrimaryMiddleware(window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		write := !isSafeMethod(c.Request.Method)
+
+		_, err := c.Cookie(primaryCookie)
+		if write || err == nil {
+			c.Request = c.Request.WithContext(database.WithPrimary(c.Request.Context()))
+		}
+
+		// The cookie has to be set before the handler writes the response
+		if write && window > 0 {
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     primaryCookie,
+				Value:    "1",
+				Path:     "/",
+				MaxAge:   int((window + time.Second - 1) / time.Second),
+				HttpOnly: true,
+				Secure:   c.Request.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		c.Next()
+	}
+}
+
+// isSafeMethod reports whether an HTTP method is read-only
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,13 +1,16 @@
 package middleware
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	logger "example.com/utils"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -105,11 +108,15 @@ func RequestIDMiddleware() gin.HandlerFunc {
 		c.Set("requestID", requestID)
 		c.Header("X-Request-ID", requestID)
 
+		// Also expose it on the request context for code that only sees a context.Context
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+
 		c.Next()
 	}
 }
 
-// BodyLoggingMiddleware logs request and response bodies (use carefully in production)
+// BodyLoggingMiddleware logs request and response bodies (use carefully in
+// production). Passwords, tokens and secrets in JSON and form bodies are redacted.
 func BodyLoggingMiddleware(logger *logger.Logger, maxBodySize int64) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := getRequestID(c)
@@ -121,7 +128,7 @@ func BodyLoggingMiddleware(logger *logger.Logger, maxBodySize int64) gin.Handler
 
 				logger.Debug("Request body", map[string]interface{}{
 					"request_id": requestID,
-					"body":       string(body),
+					"body":       redactBody(c.ContentType(), body),
 					"size":       len(body),
 				})
 			}
@@ -145,6 +152,7 @@ func ErrorLoggingMiddleware(logger *logger.Logger) gin.HandlerFunc {
 					"request_id": requestID,
 					"method":     c.Request.Method,
 					"path":       c.Request.URL.Path,
+					"error":      err.Error(),
 					"error_type": err.Type,
 				}
 
@@ -200,6 +208,75 @@ func getRequestID(c *gin.Context) string {
 }
 
 // shouldLogBody determines if request body should be logged based on content type
+// sensitiveFields are substrings of the names of body fields whose values
+// are never logged, e.g. new_password and refresh_token
+var sensitiveFields = []string{"password", "token", "secret"}
+
+// redacted replaces the values of sensitive fields
+const redacted = "[REDACTED]"
+
+// redactBody returns body with the values of sensitive fields redacted.
+// Handlers bind JSON whatever the content type, so every body is tried as
+// JSON; other bodies than JSON and forms are returned unchanged.
+func redactBody(contentType string, body []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err == nil {
+		if redactedJSON, err := json.Marshal(redactValue(value)); err == nil {
+			return string(redactedJSON)
+		}
+	}
+
+	// A truncated or malformed JSON body cannot be redacted field by field
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return redacted
+	}
+
+	if contentType == "application/x-www-form-urlencoded" {
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			for key := range values {
+				if isSensitiveField(key) {
+					values[key] = []string{redacted}
+				}
+			}
+			return values.Encode()
+		}
+	}
+
+	return string(body)
+}
+
+// redactValue redacts the sensitive fields of decoded JSON, at any depth
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSensitiveField(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+// isSensitiveField reports whether the value of a field must not be logged
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, sensitive := range sensitiveFields {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
 func shouldLogBody(req *http.Request) bool {
 	contentType := req.Header.Get("Content-Type")
 
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestLogger returns a logger writing to a temporary directory, and a
+// function reading everything logged so far
+func newTestLogger(t *testing.T) (*utils.Logger, func() string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	log, err := utils.NewLoggerWithConfig(utils.LoggerConfig{LogDir: dir, JSONFormat: true, Level: utils.LogLevel("debug")})
+	if err != nil {
+		t.Fatalf("NewLoggerWithConfig = %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+
+	return log, func() string {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+		var b strings.Builder
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("ReadFile = %v", err)
+			}
+			b.Write(content)
+		}
+		return b.String()
+	}
+}
+
+func TestErrorLoggingMiddlewareLogsCause(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, logged := newTestLogger(t)
+
+	r := gin.New()
+	r.Use(ErrorLoggingMiddleware(log))
+	r.GET("/", func(c *gin.Context) {
+		c.Error(errors.New("connection refused"))
+		c.Status(http.StatusInternalServerError)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if out := logged(); !strings.Contains(out, "connection refused") {
+		t.Errorf("log = %q, want the error text", out)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"json", "application/json", `{"email":"ada@example.com","password":"hunter22"}`, `{"email":"ada@example.com","password":"[REDACTED]"}`},
+		{"nested json", "application/json", `{"user":{"new_password":"x"},"tokens":[1],"n":1.50}`, `{"n":1.50,"tokens":"[REDACTED]","user":{"new_password":"[REDACTED]"}}`},
+		{"json as text", "text/plain", `{"refresh_token":"abc"}`, `{"refresh_token":"[REDACTED]"}`},
+		{"truncated json", "application/json", `{"password":"hun`, `[REDACTED]`},
+		{"form", "application/x-www-form-urlencoded", `client_secret=s3cret&grant_type=password`, `client_secret=%5BREDACTED%5D&grant_type=password`},
+		{"text", "text/plain", `password=plain text`, `password=plain text`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactBody(tt.contentType, []byte(tt.body)); got != tt.want {
+				t.Errorf("redactBody = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBodyLoggingMiddlewareRedactsCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, logged := newTestLogger(t)
+
+	var received string
+	r := gin.New()
+	r.Use(BodyLoggingMiddleware(log, 1024))
+	r.POST("/auth/login", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		received = string(body)
+		c.Status(http.StatusOK)
+	})
+
+	body := `{"email":"ada@example.com","password":"hunter22"}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != body {
+		t.Errorf("handler received %q, want the original body", received)
+	}
+	out := logged()
+	if strings.Contains(out, "hunter22") || !strings.Contains(out, "ada@example.com") {
+		t.Errorf("log = %q, want the body with the password redacted", out)
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"example.com/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey is the gin.Context key holding the *Principal
+const principalKey = "principal"
+
+// principalContextKey is the request context key holding the *Principal
+type principalContextKey struct{}
+
+// Kinds of principal
+const (
+	PrincipalToken      = "token"
+	PrincipalClientCert = "client_cert"
+)
+
+// ClientCertSubjectPrefix namespaces the subjects of client certificates, so
+// that a certificate named "1" is never taken for user 1
+const ClientCertSubjectPrefix = "cert:"
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Kind      string    `json:"kind"`
+	Subject   string    `json:"subject"`
+	Roles     []string  `json:"roles,omitempty"`
+	Scopes    []string  `json:"scopes,omitempty"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	TokenID   string    `json:"token_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+
+	// Claims holds the parsed access token, or nil for client certificates
+	Claims *utils.Claims `json:"-"`
+}
+
+// principalFromClaims builds the Principal of a validated access token
+func principalFromClaims(claims *utils.Claims) *Principal {
+	principal := &Principal{
+		Kind:     PrincipalToken,
+		Subject:  claims.Subject,
+		Roles:    claims.Roles,
+		Scopes:   claims.Scopes,
+		TenantID: claims.TenantID,
+		TokenID:  claims.ID,
+		Claims:   claims,
+	}
+	if claims.ExpiresAt != nil {
+		principal.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return principal
+}
+
+// principalFromClientIdentity builds the Principal of a verified client
+// certificate, with the roles clientRoles grants its name
+func principalFromClientIdentity(identity *ClientIdentity, clientRoles ClientRoles) *Principal {
+	principal := &Principal{
+		Kind:    PrincipalClientCert,
+		Subject: ClientCertSubjectPrefix + identity.Name(),
+	}
+	if clientRoles != nil {
+		principal.Roles = clientRoles(identity)
+	}
+	return principal
+}
+
+// setPrincipal stores the principal on the gin.Context and the request context
+func setPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(principalKey, principal)
+	c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), principal))
+}
+
+// GetPrincipal returns the authenticated caller, if any
+func GetPrincipal(c *gin.Context) (*Principal, bool) {
+	if value, exists := c.Get(principalKey); exists {
+		if principal, ok := value.(*Principal); ok {
+			return principal, true
+		}
+	}
+	return nil, false
+}
+
+// GetSubject returns the subject of the authenticated caller, or "" if there is none
+func GetSubject(c *gin.Context) string {
+	if principal, ok := GetPrincipal(c); ok {
+		return principal.Subject
+	}
+	return ""
+}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal carried by a request context,
+// for code below the handlers that only sees a context.Context
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok
+}
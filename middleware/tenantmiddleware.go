@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"example.com/config"
+	"example.com/database"
+	"example.com/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// tenantIDKey is the gin.Context key holding the resolved tenant ID
+const tenantIDKey = "tenantID"
+
+// tenantIDPattern restricts tenant IDs to slugs and UUIDs
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// errNoTenantClaim is returned by TenantFromClaim for verified tokens
+// without a tenant claim
+var errNoTenantClaim = errors.New("credentials carry no tenant")
+
+// TenantSource extracts a tenant ID from a request, returning "" if it has
+// none. An error rejects the request whatever the other sources say.
+type TenantSource func(c *gin.Context) (string, error)
+
+// TenantFromHeader reads the tenant ID from a request header. The header is
+// set by the client, so on its own it lets callers pick any tenant; combine
+// it with TenantFromClaim unless a trusted proxy sets it.
+func TenantFromHeader(name string) TenantSource {
+	return func(c *gin.Context) (string, error) {
+		return strings.TrimSpace(c.GetHeader(name)), nil
+	}
+}
+
+// TenantFromSubdomain reads the tenant ID from the first label of a host
+// under baseDomain, e.g. acme for acme.example.com
+func TenantFromSubdomain(baseDomain string) TenantSource {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+
+	return func(c *gin.Context) (string, error) {
+		host := strings.ToLower(c.Request.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		subdomain, ok := strings.CutSuffix(host, suffix)
+		if !ok || strings.Contains(subdomain, ".") {
+			return "", nil
+		}
+		return subdomain, nil
+	}
+}
+
+// TenantFromClaim reads the tenant ID from a claim of the request's access
+// token. The token is verified here, so the claim can be trusted even before
+// AuthMiddleware runs. A valid token without the claim is an error, so its
+// holder cannot choose a tenant through another source; requests without a
+// valid token are left to AuthMiddleware.
+func TenantFromClaim(tokens *utils.TokenService, claim string, extractors ...TokenExtractor) TenantSource {
+	if len(extractors) == 0 {
+		extractors = defaultExtractors
+	}
+
+	return func(c *gin.Context) (string, error) {
+		token := extractToken(c, extractors)
+		if token == "" {
+			return "", nil
+		}
+
+		claims, err := tokens.ParseClaims(token)
+		if err != nil {
+			return "", nil
+		}
+
+		if tenantID, ok := claims[claim].(string); ok && tenantID != "" {
+			return tenantID, nil
+		}
+		return "", errNoTenantClaim
+	}
+}
+
+// TenantSources builds the configured tenant sources, in order. Claims are
+// read from the token found by extractors.
+func TenantSources(cfg config.TenantConfig, tokens *utils.TokenService, extractors []TokenExtractor) []TenantSource {
+	sources := make([]TenantSource, 0, len(cfg.Sources))
+	for _, source := range cfg.Sources {
+		switch strings.TrimSpace(source) {
+		case "claim":
+			sources = append(sources, TenantFromClaim(tokens, cfg.Claim, extractors...))
+		case "subdomain":
+			sources = append(sources, TenantFromSubdomain(cfg.BaseDomain))
+		case "header":
+			sources = append(sources, TenantFromHeader(cfg.Header))
+		}
+	}
+	return sources
+}
+
+// TenantMiddleware resolves the tenant of a request and scopes the request
+// context to it, so tenant-scoped models only see that tenant's rows.
+// Requests without a tenant are rejected with 400, and requests whose
+// sources disagree, e.g. a header naming another tenant than the token, or
+// whose token carries no tenant, with 403.
+func TenantMiddleware(sources ...TenantSource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := ""
+		for _, source := range sources {
+			candidate, err := source(c)
+			if err != nil {
+				abortForbidden(c, err.Error())
+				return
+			}
+			if candidate == "" {
+				continue
+			}
+
+			if tenantID != "" && candidate != tenantID {
+				abortForbidden(c, "tenant does not match credentials")
+				return
+			}
+			tenantID = candidate
+		}
+
+		if !tenantIDPattern.MatchString(tenantID) {
+			msg := "tenant could not be resolved"
+			if tenantID != "" {
+				msg = fmt.Sprintf("invalid tenant %q", tenantID)
+			}
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Bad Request",
+				"msg":   msg,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set(tenantIDKey, tenantID)
+		c.Request = c.Request.WithContext(database.WithTenant(c.Request.Context(), tenantID))
+
+		c.Next()
+	}
+}
+
+// GetTenantID returns the tenant resolved for the request, if any
+func GetTenantID(c *gin.Context) (string, bool) {
+	if value, exists := c.Get(tenantIDKey); exists {
+		if tenantID, ok := value.(string); ok {
+			return tenantID, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/config"
+	"example.com/database"
+	"github.com/gin-gonic/gin"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := newTestTokens(t)
+
+	cfg := config.TenantConfig{
+		Sources:    []string{"claim", "subdomain", "header"},
+		Header:     "X-Tenant-ID",
+		BaseDomain: "example.com",
+		Claim:      "tenant_id",
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		host       string
+		header     string
+		wantStatus int
+		wantTenant string
+	}{
+		{"claim", signToken(t, tokens, "acme"), "", "", http.StatusOK, "acme"},
+		{"claim matching header", signToken(t, tokens, "acme"), "", "acme", http.StatusOK, "acme"},
+		{"claim matching subdomain", signToken(t, tokens, "acme"), "acme.example.com", "", http.StatusOK, "acme"},
+		{"header naming another tenant", signToken(t, tokens, "acme"), "", "globex", http.StatusForbidden, ""},
+		{"subdomain naming another tenant", signToken(t, tokens, "acme"), "globex.example.com:8080", "", http.StatusForbidden, ""},
+		{"token without claim and header", signToken(t, tokens, ""), "", "globex", http.StatusForbidden, ""},
+		{"token without claim", signToken(t, tokens, ""), "", "", http.StatusForbidden, ""},
+		{"no token with header", "", "", "globex", http.StatusOK, "globex"},
+		{"invalid token with header", "not-a-token", "", "globex", http.StatusOK, "globex"},
+		{"nothing", "", "", "", http.StatusBadRequest, ""},
+		{"invalid tenant", "", "", "acme corp", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTenant string
+			r := gin.New()
+			r.Use(TenantMiddleware(TenantSources(cfg, tokens, nil)...))
+			r.GET("/", func(c *gin.Context) {
+				gotTenant, _ = database.TenantFromContext(c.Request.Context())
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if gotTenant != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", gotTenant, tt.wantTenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"time"
+
+	"example.com/database"
+	"gorm.io/gorm"
+)
+
+// refreshToken20261016 is the refresh_tokens schema as of this migration.
+// It is a copy rather than models.RefreshToken so later model changes do not
+// alter what this migration creates.
+type refreshToken20261016 struct {
+	ID        uint      `gorm:"primaryKey"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	FamilyID  string    `gorm:"size:32;not null;index"`
+	Subject   string    `gorm:"size:255;not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (refreshToken20261016) TableName() string {
+	return "refresh_tokens"
+}
+
+func init() {
+	register(database.Migration{
+		Version: 20261016000001,
+		Name:    "create_refresh_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&refreshToken20261016{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("refresh_tokens")
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"time"
+
+	"example.com/database"
+	"gorm.io/gorm"
+)
+
+// user20261016 is the users schema as of this migration
+type user20261016 struct {
+	ID           uint   `gorm:"primaryKey"`
+	Email        string `gorm:"size:255;not null;uniqueIndex"`
+	Name         string `gorm:"size:255"`
+	PasswordHash string `gorm:"size:255;not null"`
+	Role         string `gorm:"size:32;not null"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+func (user20261016) TableName() string {
+	return "users"
+}
+
+func init() {
+	register(database.Migration{
+		Version: 20261016000002,
+		Name:    "create_users",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&user20261016{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("users")
+		},
+	})
+}
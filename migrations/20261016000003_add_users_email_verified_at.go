@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"example.com/database"
+	"gorm.io/gorm"
+)
+
+// user20261016EmailVerified is the users column added by this migration
+type user20261016EmailVerified struct {
+	EmailVerifiedAt *time.Time
+}
+
+func (user20261016EmailVerified) TableName() string {
+	return "users"
+}
+
+func init() {
+	register(database.Migration{
+		Version: 20261016000003,
+		Name:    "add_users_email_verified_at",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&user20261016EmailVerified{}, "EmailVerifiedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&user20261016EmailVerified{}, "EmailVerifiedAt")
+		},
+	})
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"example.com/database"
+	"gorm.io/gorm"
+)
+
+// userToken20261016 is the user_tokens schema as of this migration
+type userToken20261016 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	Purpose   string    `gorm:"size:32;not null"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (userToken20261016) TableName() string {
+	return "user_tokens"
+}
+
+func init() {
+	register(database.Migration{
+		Version: 20261016000004,
+		Name:    "create_user_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&userToken20261016{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("user_tokens")
+		},
+	})
+}
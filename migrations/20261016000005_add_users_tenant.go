@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"example.com/database"
+	"gorm.io/gorm"
+)
+
+// user20261016Tenant is the users column added by this migration
+type user20261016Tenant struct {
+	Tenant string `gorm:"size:64;index"`
+}
+
+func (user20261016Tenant) TableName() string {
+	return "users"
+}
+
+func init() {
+	register(database.Migration{
+		Version: 20261016000005,
+		Name:    "add_users_tenant",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&user20261016Tenant{}, "Tenant"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&user20261016Tenant{}, "Tenant")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&user20261016Tenant{}, "Tenant"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&user20261016Tenant{}, "Tenant")
+		},
+	})
+}
@@ -0,0 +1,36 @@
+// Package migrations holds the application's versioned schema migrations.
+//
+// SQL migrations live in the sql directory as <version>_<name>.up.sql and
+// <version>_<name>.down.sql and are embedded into the binary. Migrations that
+// need Go code are added with register from an init function in this package.
+package migrations
+
+import (
+	"embed"
+
+	"example.com/database"
+)
+
+//go:embed all:sql
+var sqlFiles embed.FS
+
+// registered holds the Go migrations added via register
+var registered []database.Migration
+
+// register adds a Go migration; call it from an init function
+func register(migration database.Migration) {
+	registered = append(registered, migration)
+}
+
+// All returns every Go and SQL migration
+func All() ([]database.Migration, error) {
+	sqlMigrations, err := database.LoadMigrationsFS(sqlFiles, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	all := make([]database.Migration, 0, len(registered)+len(sqlMigrations))
+	all = append(all, registered...)
+	all = append(all, sqlMigrations...)
+	return all, nil
+}
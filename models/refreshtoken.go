@@ -0,0 +1,18 @@
+// Package models holds the persisted domain types shared across packages
+package models
+
+import "time"
+
+// RefreshToken is a stored refresh token. Only the SHA-256 hash of the token
+// is kept. Tokens rotated from the same login share a FamilyID, so a stolen
+// token can be revoked together with all of its successors.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex"`
+	FamilyID  string     `gorm:"size:32;not null;index"`
+	Subject   string     `gorm:"size:255;not null;index"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	UsedAt    *time.Time // Set when the token is exchanged for a new pair
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User is an account that signs in with email and password. A user belongs
+// to a tenant but is not tenant-scoped, hence no tenant_id column: users sign
+// in before a tenant is known, and emails are unique across tenants.
+type User struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	Email           string         `gorm:"size:255;not null;uniqueIndex" json:"email"` // Stored lower-cased
+	Name            string         `gorm:"size:255" json:"name"`
+	PasswordHash    string         `gorm:"size:255;not null" json:"-"`
+	Role            string         `gorm:"size:32;not null" json:"role"`
+	Tenant          string         `gorm:"size:64;index" json:"tenant,omitempty"` // Carried in access tokens
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Purposes of a UserToken
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// UserToken is a single-use token emailed to a user, e.g. to verify the
+// address or reset the password. Only the SHA-256 hash is kept.
+type UserToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	Purpose   string     `gorm:"size:32;not null"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // Set once consumed or invalidated
+	CreatedAt time.Time
+}
@@ -1,26 +1,52 @@
 package routes
 
 import (
+	"strings"
+
+	"example.com/cache"
 	"example.com/config"
 	"example.com/controllers"
+	"example.com/database"
+	"example.com/health"
+	"example.com/mail"
 	"example.com/middleware"
-	_ "example.com/utils"
+	"example.com/models"
 	logger "example.com/utils"
 	"github.com/gin-gonic/gin"
-	"log"
 )
 
-func SetupRouter(config config.LoggerConfig) *gin.Engine {
-	// Initialize logger
-	appLogger, err := logger.NewLogger(config)
-	if err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
-	}
-	defer appLogger.Close()
+// Dependencies holds the shared services injected into the router
+type Dependencies struct {
+	Logger *logger.Logger
+	DB     *database.DbInstance
+	Cache  cache.Cache
+	Health *health.Registry
+	Tokens *logger.TokenService
+	Mailer mail.Mailer
+	Tasks  *logger.Tasks
+}
+
+// NewRouter builds the gin engine with all middleware and routes registered.
+// It does not start listening; serving is left to the caller.
+func NewRouter(cfg *config.Config, deps Dependencies) *gin.Engine {
+	appLogger := deps.Logger
 
 	// Create Gin router
 	r := gin.New()
 
+	// Client IPs come from X-Forwarded-For only when set by a trusted proxy;
+	// otherwise any client could pick its IP. Validate rejects invalid entries.
+	proxies := make([]string, 0, len(cfg.Server.TrustedProxies))
+	for _, proxy := range cfg.Server.TrustedProxies {
+		proxies = append(proxies, strings.TrimSpace(proxy))
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		appLogger.Error("Invalid trusted proxies, trusting none", map[string]interface{}{
+			"error": err.Error(),
+		})
+		r.SetTrustedProxies(nil)
+	}
+
 	// Add middleware in order of execution
 	r.Use(middleware.RequestIDMiddleware())
 
@@ -30,33 +56,74 @@ func SetupRouter(config config.LoggerConfig) *gin.Engine {
 	// Main logging middleware
 	r.Use(middleware.LoggerMiddleware(middleware.MiddlewareConfig{
 		Logger:            appLogger,
-		SkipPaths:         []string{"/health", "/metrics"}, // Skip logging for these paths
-		EnableBodyLogging: false,                           // Enable only if needed
-		MaxBodySize:       32 * 1024,                       // 32KB
+		SkipPaths:         []string{"/health/live", "/health/ready", "/metrics"}, // Skip logging for these paths
+		EnableBodyLogging: false,                                                 // Enable only if needed
+		MaxBodySize:       32 * 1024,                                             // 32KB
 	}))
 
 	// Error logging middleware
 	r.Use(middleware.ErrorLoggingMiddleware(appLogger))
 
-	if gin.Mode() == gin.DebugMode {
+	if cfg.Server.Mode == gin.DebugMode {
 		r.Use(middleware.BodyLoggingMiddleware(appLogger, 1024)) // 1KB limit for dev
 	}
 
+	// Keep clients on the primary database briefly after they write
+	if len(cfg.Database.Replicas) > 0 {
+		r.Use(middleware.StickyPrimaryMiddleware(cfg.Database.StickyPrimary))
+	}
+
+	// Expose verified mTLS client identities to the auth middleware
+	r.Use(middleware.ClientCertMiddleware())
+
 	r.GET("/ping", controllers.Ping)
 
-	r.Use(middleware.AuthMiddleware)
+	// Kubernetes probes
+	r.GET("/health/live", controllers.Live)
+	r.GET("/health/ready", controllers.Ready(deps.Health))
 
-	// Start server
-	appLogger.Info("Starting server", map[string]interface{}{
-		"port": "8080",
-		"mode": gin.Mode(),
-	})
+	r.GET("/metrics", controllers.Metrics(deps.DB))
 
-	if err := r.Run(":8080"); err != nil {
-		appLogger.Error("Failed to start server", map[string]interface{}{
-			"error": err.Error(),
-		})
-		log.Fatalf("Failed to start server: %v", err)
+	extractors := middleware.TokenExtractors(cfg.JWT)
+	requireAuth := middleware.AuthMiddleware(deps.Tokens, extractors...)
+	if cfg.Server.TLS.ClientCAFile != "" {
+		// Internal callers may present a verified client certificate instead
+		clientRoles := middleware.ClientRolesFromConfig(cfg.Server.TLS.ClientRoles)
+		requireAuth = middleware.AuthOrClientCertMiddleware(deps.Tokens, clientRoles, extractors...)
 	}
+
+	var scopeTenant gin.HandlerFunc
+	if cfg.Tenant.Enabled {
+		scopeTenant = middleware.TenantMiddleware(middleware.TenantSources(cfg.Tenant, deps.Tokens, extractors)...)
+	}
+
+	// Signing in, renewal and logout have to work without a valid access token
+	accountController := controllers.NewAccountController(cfg.Email, deps.DB, deps.Tokens, deps.Mailer, deps.Tasks, appLogger)
+	authController := controllers.NewAuthController(cfg.JWT, deps.Tokens, database.NewRepository[models.User](deps.DB), accountController)
+	auth := r.Group("/auth")
+	if scopeTenant != nil {
+		// New users join the tenant of the request
+		auth.POST("/register", scopeTenant, authController.Register)
+	} else {
+		auth.POST("/register", authController.Register)
+	}
+	auth.POST("/login", authController.Login)
+	auth.POST("/logout", authController.Logout)
+	auth.POST("/refresh", authController.Refresh)
+	auth.GET("/me", requireAuth, authController.Me)
+	auth.POST("/verify-email/request", requireAuth, accountController.RequestVerification)
+	auth.POST("/verify-email/confirm", accountController.ConfirmVerification)
+	auth.POST("/password-reset/request", accountController.RequestPasswordReset)
+	auth.POST("/password-reset/confirm", accountController.ConfirmPasswordReset)
+	auth.POST("/password", requireAuth, accountController.ChangePassword)
+	r.GET("/.well-known/jwks.json", authController.JWKS)
+
+	// Scope everything below to the caller's tenant
+	if scopeTenant != nil {
+		r.Use(scopeTenant)
+	}
+
+	r.Use(requireAuth)
+
 	return r
 }
@@ -0,0 +1,207 @@
+package routes_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/cache"
+	"example.com/config"
+	"example.com/database"
+	"example.com/database/dbtest"
+	"example.com/health"
+	"example.com/mail"
+	"example.com/migrations"
+	"example.com/models"
+	"example.com/routes"
+	"example.com/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// testConfig is a minimal configuration for building the router
+func testConfig() *config.Config {
+	return &config.Config{
+		Server: config.ServerConfig{Mode: gin.TestMode},
+		JWT: config.JWTConfig{
+			Secret:          "test-secret-that-is-at-least-32-bytes",
+			Issuer:          "test",
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+			Algorithm:       "HS256",
+			TokenSources:    []string{"header"},
+		},
+	}
+}
+
+// newTestRouter builds the router for cfg on a migrated test database
+func newTestRouter(t *testing.T, cfg *config.Config) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	all, err := migrations.All()
+	if err != nil {
+		t.Fatalf("migrations.All = %v", err)
+	}
+	db := dbtest.New(t, dbtest.Options{Migrations: all})
+
+	log, err := utils.NewLoggerWithConfig(utils.LoggerConfig{LogDir: t.TempDir(), Level: utils.LogLevel("debug")})
+	if err != nil {
+		t.Fatalf("NewLoggerWithConfig = %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+
+	tokens, err := utils.NewTokenService(cfg.JWT, database.NewRefreshTokenStore(db))
+	if err != nil {
+		t.Fatalf("NewTokenService = %v", err)
+	}
+
+	// Background sends finish before the database is closed
+	tasks := utils.NewTasks()
+	t.Cleanup(func() { tasks.Wait(context.Background()) })
+
+	return routes.NewRouter(cfg, routes.Dependencies{
+		Logger: log,
+		DB:     db,
+		Cache:  cache.NewMemory(),
+		Health: health.NewRegistry(time.Second),
+		Tokens: tokens,
+		Mailer: mail.New(cfg.Email, cfg.Server.Mode, log),
+		Tasks:  tasks,
+	})
+}
+
+func TestRoutesAcceptClientCertificates(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server.TLS.ClientCAFile = "ca.pem"
+	cfg.Server.TLS.ClientRoles = []string{"billing=admin"}
+
+	r := newTestRouter(t, cfg)
+	r.GET("/internal", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := map[string]struct {
+		cert *x509.Certificate
+		want int
+	}{
+		"client certificate": {&x509.Certificate{Subject: pkix.Name{CommonName: "billing"}, SerialNumber: big.NewInt(1)}, http.StatusOK},
+		"nothing":            {nil, http.StatusUnauthorized},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/internal", nil)
+			if tt.cert != nil {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tt.cert}}}
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestRoutesRejectClientCertificatesWithoutClientCA(t *testing.T) {
+	r := newTestRouter(t, testConfig())
+	r.GET("/internal", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/internal", nil)
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}, SerialNumber: big.NewInt(1)}
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRoutesRegisterJoinsRequestTenant(t *testing.T) {
+	cfg := testConfig()
+	cfg.Tenant = config.TenantConfig{
+		Enabled: true,
+		Sources: []string{"claim", "header"},
+		Header:  "X-Tenant-ID",
+		Claim:   "tenant_id",
+	}
+	r := newTestRouter(t, cfg)
+
+	register := func(tenant string) *httptest.ResponseRecorder {
+		body := strings.NewReader(`{"email":"ada@example.com","password":"correct horse"}`)
+		req := httptest.NewRequest(http.MethodPost, "/auth/register", body)
+		req.Header.Set("Content-Type", "application/json")
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := register(""); w.Code != http.StatusBadRequest {
+		t.Fatalf("register without tenant = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+
+	w := register("acme")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	var resp struct {
+		User models.User `json:"user"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.User.Tenant != "acme" {
+		t.Errorf("registered user = %+v, %v, want tenant acme", resp.User, err)
+	}
+}
+
+func TestRoutesThrottlePasswordResets(t *testing.T) {
+	r := newTestRouter(t, testConfig())
+
+	reset := func(remoteAddr, forwardedFor, email string) int {
+		body := strings.NewReader(`{"email":"` + email + `"}`)
+		req := httptest.NewRequest(http.MethodPost, "/auth/password-reset/request", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Requests for one address from one client
+	for i := 0; i < 3; i++ {
+		if code := reset("192.0.2.1:1000", "", "victim@example.com"); code != http.StatusAccepted {
+			t.Fatalf("reset %d = %d, want %d", i, code, http.StatusAccepted)
+		}
+	}
+	if code := reset("192.0.2.1:1000", "", "victim@example.com"); code != http.StatusTooManyRequests {
+		t.Fatalf("reset over the per-address limit = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	// The owner of the address is not locked out by another client
+	if code := reset("198.51.100.7:1000", "", "victim@example.com"); code != http.StatusAccepted {
+		t.Errorf("reset from another client = %d, want %d", code, http.StatusAccepted)
+	}
+
+	// X-Forwarded-For from an untrusted peer does not reset the per-IP limit
+	for i := 0; ; i++ {
+		forwarded := "203.0.113." + strconv.Itoa(i)
+		if code := reset("192.0.2.1:1000", forwarded, "user"+strconv.Itoa(i)+"@example.com"); code == http.StatusTooManyRequests {
+			break
+		}
+		if i > 20 {
+			t.Fatal("spoofed X-Forwarded-For bypassed the per-IP limit")
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Package seeds holds the application's development and test seed data.
+//
+// Fixture files live in the fixtures directory as YAML or JSON lists and are
+// embedded into the binary. Seeds are added with register from an init
+// function in this package, for example
+//
+//	register(database.FixtureSeed[models.User]("users", fixtures, "fixtures/users.yaml"))
+package seeds
+
+import (
+	"embed"
+
+	"example.com/database"
+)
+
+//go:embed all:fixtures
+var fixtures embed.FS
+
+// registered holds the seeds added via register, in run order
+var registered []database.Seed
+
+// register adds a seed; call it from an init function
+func register(seed database.Seed) {
+	registered = append(registered, seed)
+}
+
+// All returns every registered seed
+func All() []database.Seed {
+	return registered
+}
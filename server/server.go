@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"example.com/config"
+	logger "example.com/utils"
+)
+
+// Server wraps an http.Server built from the application's ServerConfig
+type Server struct {
+	config         config.ServerConfig
+	httpServer     *http.Server
+	redirectServer *http.Server // Plain HTTP to HTTPS redirect, nil unless enabled
+	logger         *logger.Logger
+}
+
+// New creates a new Server serving the given handler with the given configuration
+func New(cfg config.ServerConfig, handler http.Handler, appLogger *logger.Logger) (*Server, error) {
+	s := &Server{
+		config: cfg,
+		logger: appLogger,
+		httpServer: &http.Server{
+			Addr:         net.JoinHostPort(cfg.Host, cfg.Port),
+			Handler:      handler,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		},
+	}
+
+	if cfg.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(cfg.TLS, appLogger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		s.httpServer.TLSConfig = tlsConfig
+
+		if cfg.TLS.RedirectHTTP {
+			s.redirectServer = &http.Server{
+				Addr:         net.JoinHostPort(cfg.Host, cfg.TLS.RedirectPort),
+				Handler:      redirectHandler(cfg.Port),
+				ReadTimeout:  cfg.ReadTimeout,
+				WriteTimeout: cfg.WriteTimeout,
+				IdleTimeout:  cfg.IdleTimeout,
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.httpServer.Addr
+}
+
+// Run starts the server and blocks until ctx is cancelled or the server fails.
+// On cancellation in-flight requests are drained within ShutdownTimeout.
+func (s *Server) Run(ctx context.Context) error {
+	errCh := make(chan error, 2)
+
+	go func() {
+		s.logger.Info("Starting server", map[string]interface{}{
+			"addr": s.httpServer.Addr,
+			"mode": s.config.Mode,
+			"tls":  s.config.TLS.Enabled,
+		})
+
+		var err error
+		if s.config.TLS.Enabled {
+			// Certificates come from TLSConfig.GetCertificate
+			err = s.httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = s.httpServer.ListenAndServe()
+		}
+		errCh <- err
+	}()
+
+	if s.redirectServer != nil {
+		go func() {
+			s.logger.Info("Starting HTTP redirect server", map[string]interface{}{
+				"addr": s.redirectServer.Addr,
+			})
+			errCh <- s.redirectServer.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		// ErrServerClosed is returned after a successful Shutdown call
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.Shutdown()
+			return fmt.Errorf("server failed: %w", err)
+		}
+	case <-ctx.Done():
+	}
+
+	return s.Shutdown()
+}
+
+// Shutdown gracefully stops the server, waiting at most ShutdownTimeout for
+// in-flight requests to complete before forcibly closing connections
+func (s *Server) Shutdown() error {
+	timeout := s.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+
+	s.logger.Info("Shutting down server", map[string]interface{}{
+		"timeout": timeout.String(),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if s.redirectServer != nil {
+		s.redirectServer.Shutdown(ctx)
+	}
+
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		// Deadline exceeded, drop whatever is still open
+		s.httpServer.Close()
+		return fmt.Errorf("failed to shutdown server gracefully: %w", err)
+	}
+
+	s.logger.Info("Server stopped", nil)
+	return nil
+}
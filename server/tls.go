@@ -0,0 +1,257 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/config"
+	logger "example.com/utils"
+)
+
+// certReloader serves the certificate from disk and reloads it when the
+// cert or key file changes, so rotated certificates are picked up without
+// a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	logger   *logger.Logger
+
+	mutex     sync.RWMutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+// newCertReloader loads the initial certificate, failing if it is unreadable
+func newCertReloader(cfg config.TLSConfig, appLogger *logger.Logger) (*certReloader, error) {
+	r := &certReloader{
+		certFile: cfg.CertFile,
+		keyFile:  cfg.KeyFile,
+		interval: cfg.ReloadInterval,
+		logger:   appLogger,
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+// maybeReload checks the files at most once per interval and reloads them if
+// either modification time changed. Failures keep the current certificate.
+func (r *certReloader) maybeReload() {
+	r.mutex.RLock()
+	due := time.Since(r.lastCheck) >= r.interval
+	r.mutex.RUnlock()
+
+	if !due {
+		return
+	}
+
+	r.mutex.Lock()
+	r.lastCheck = time.Now()
+	certMod, keyMod := r.certMod, r.keyMod
+	r.mutex.Unlock()
+
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		r.logger.Warn("Failed to stat TLS certificate", map[string]interface{}{
+			"file":  r.certFile,
+			"error": err.Error(),
+		})
+		return
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		r.logger.Warn("Failed to stat TLS key", map[string]interface{}{
+			"file":  r.keyFile,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if certInfo.ModTime().Equal(certMod) && keyInfo.ModTime().Equal(keyMod) {
+		return
+	}
+
+	if err := r.reload(); err != nil {
+		r.logger.Warn("Failed to reload TLS certificate, keeping current one", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	r.logger.Info("Reloaded TLS certificate", map[string]interface{}{
+		"cert_file": r.certFile,
+	})
+}
+
+// reload reads the key pair from disk and swaps it in
+func (r *certReloader) reload() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat key: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load key pair: %w", err)
+	}
+
+	r.mutex.Lock()
+	r.cert = &cert
+	r.certMod = certInfo.ModTime()
+	r.keyMod = keyInfo.ModTime()
+	r.lastCheck = time.Now()
+	r.mutex.Unlock()
+
+	return nil
+}
+
+// newTLSConfig builds the tls.Config for the HTTPS listener
+func newTLSConfig(cfg config.TLSConfig, appLogger *logger.Logger) (*tls.Config, error) {
+	minVersion, err := parseTLSVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherSuites, err := parseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader, err := newCertReloader(cfg, appLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if cfg.ClientCAFile != "" {
+		clientCAs, err := loadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		clientAuth, err := parseClientAuth(cfg.ClientAuth)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = clientAuth
+	}
+
+	return tlsConfig, nil
+}
+
+// loadCertPool reads a PEM bundle of CA certificates
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", file)
+	}
+	return pool, nil
+}
+
+// parseClientAuth converts a client auth mode name to its tls constant.
+// verify_if_given lets callers without a certificate fall back to JWT auth.
+func parseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "verify_if_given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "none":
+		return tls.NoClientCert, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS client auth mode %q", mode)
+	}
+}
+
+// parseTLSVersion converts a version string such as "1.2" to its tls constant
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS min version %q", version)
+	}
+}
+
+// parseCipherSuites maps Go cipher suite names to their IDs. Insecure suites
+// are rejected. An empty list leaves the choice to the Go defaults.
+// Note that cipher suites are not configurable for TLS 1.3.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	available := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		available[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		id, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// redirectHandler redirects every plain HTTP request to its HTTPS equivalent
+func redirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
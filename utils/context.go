@@ -0,0 +1,26 @@
+package utils
+
+import "context"
+
+// requestIDKey is the context key holding the request ID
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or "" if none.
+// A *gin.Context is also accepted, as it exposes the ID under "requestID".
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	if id, ok := ctx.Value("requestID").(string); ok {
+		return id
+	}
+	return ""
+}
@@ -1,33 +1,175 @@
 package utils
 
 import (
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"example.com/config"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-const SecretKey = "secret"
+// ErrInvalidToken is returned for tokens that fail signature or claim validation
+var ErrInvalidToken = errors.New("invalid token")
 
-func GenerateJwt(issuer string) (string, error) {
+// TokenService signs and validates access tokens using the JWT configuration
+// and issues refresh tokens kept in a RefreshTokenStore
+type TokenService struct {
+	method     jwt.SigningMethod
+	signingKey interface{}
+	keyID      string
+	keys       map[string]verificationKey // By kid; "" holds the key for tokens without one
+	parser     *jwt.Parser
+	issuer     string
+	ttl        time.Duration
+	refreshTTL time.Duration
+	store      RefreshTokenStore
+	loader     ClaimsLoader
+}
 
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		Issuer:    issuer,
-		ExpiresAt: time.Now().Add(time.Hour * 24).Unix(), //1day
-	})
+// NewTokenService creates a TokenService from the JWT configuration. The
+// store may be nil if only access tokens are needed.
+func NewTokenService(cfg config.JWTConfig, store RefreshTokenStore) (*TokenService, error) {
+	if cfg.AccessTokenTTL <= 0 {
+		return nil, fmt.Errorf("JWT access token TTL must be positive")
+	}
+	if store != nil && cfg.RefreshTokenTTL <= 0 {
+		return nil, fmt.Errorf("JWT refresh token TTL must be positive")
+	}
+
+	method, signingKey, current, err := loadSigningKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := loadVerificationKeys(cfg.VerificationKeys)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := keys[cfg.KeyID]; ok && cfg.KeyID != "" {
+		return nil, fmt.Errorf("JWT verification key %q duplicates the signing key ID", cfg.KeyID)
+	}
+	keys[cfg.KeyID] = current
+	// Tokens issued before key IDs were configured carry no kid
+	keys[""] = current
 
-	return claims.SignedString([]byte(SecretKey))
+	algorithms := make([]string, 0, len(keys))
+	for _, key := range keys {
+		algorithms = append(algorithms, key.algorithm)
+	}
+
+	return &TokenService{
+		method:     method,
+		signingKey: signingKey,
+		keyID:      cfg.KeyID,
+		keys:       keys,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(algorithms),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuedAt(),
+		),
+		issuer:     cfg.Issuer,
+		ttl:        cfg.AccessTokenTTL,
+		refreshTTL: cfg.RefreshTokenTTL,
+		store:      store,
+	}, nil
 }
 
-func ParseJwt(cookie string) (string, error) {
-	token, err := jwt.ParseWithClaims(cookie, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(SecretKey), nil
-	})
+// Claims are the claims of an access token. Applications can add their own
+// claims by embedding Claims in a struct passed to Sign and ParseInto.
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles    []string `json:"roles,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	TenantID string   `json:"tenant_id,omitempty"`
+}
 
-	if err != nil || !token.Valid {
+// base returns the embedded Claims; it lets ClaimsHolder accept any type
+// that embeds Claims
+func (c *Claims) base() *Claims {
+	return c
+}
+
+// ClaimsHolder is implemented by *Claims and by pointers to structs embedding it
+type ClaimsHolder interface {
+	jwt.Claims
+	base() *Claims
+}
+
+// Generate signs an access token for subject that expires after the configured TTL
+func (s *TokenService) Generate(subject string) (string, error) {
+	return s.Sign(&Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}})
+}
+
+// Sign signs an access token with the given claims. The issuer, issue time,
+// expiry and token ID are set by the service and overwrite any given values.
+func (s *TokenService) Sign(claims ClaimsHolder) (string, error) {
+	tokenID, err := randomBytes(16)
+	if err != nil {
 		return "", err
 	}
 
-	claims := token.Claims.(*jwt.StandardClaims)
+	now := time.Now()
+	registered := &claims.base().RegisteredClaims
+	registered.ID = hex.EncodeToString(tokenID)
+	registered.Issuer = s.issuer
+	registered.IssuedAt = jwt.NewNumericDate(now)
+	registered.NotBefore = jwt.NewNumericDate(now)
+	registered.ExpiresAt = jwt.NewNumericDate(now.Add(s.ttl))
+
+	token := jwt.NewWithClaims(s.method, claims)
+	if s.keyID != "" {
+		token.Header["kid"] = s.keyID
+	}
+
+	return token.SignedString(s.signingKey)
+}
 
-	return claims.Issuer, nil
+// Parse validates a token and returns its claims
+func (s *TokenService) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := s.parse(tokenString, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ParseInto validates a token like Parse and decodes its claims into claims,
+// which may carry application-specific fields
+func (s *TokenService) ParseInto(tokenString string, claims ClaimsHolder) error {
+	return s.parse(tokenString, claims)
+}
+
+// ParseClaims validates a token like Parse and returns all of its claims
+func (s *TokenService) ParseClaims(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if err := s.parse(tokenString, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// parse verifies the signature against the key named by the kid header and
+// validates the iss, exp, nbf and iat claims
+func (s *TokenService) parse(tokenString string, claims jwt.Claims) error {
+	_, err := s.parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID %q", kid)
+		}
+
+		// Pin the algorithm to the key so a token cannot choose how it is verified
+		if token.Method.Alg() != key.algorithm {
+			return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+		}
+		return key.key, nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return nil
 }
@@ -0,0 +1,350 @@
+package utils_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"example.com/config"
+	"example.com/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testSecret is an HS256 secret for test token services
+const testSecret = "test-secret-that-is-at-least-32-bytes"
+
+// hsConfig returns an HS256 configuration
+func hsConfig() config.JWTConfig {
+	return config.JWTConfig{
+		Secret:         testSecret,
+		Issuer:         "test",
+		AccessTokenTTL: time.Minute,
+		Algorithm:      "HS256",
+	}
+}
+
+// newTokens creates a TokenService without a refresh token store
+func newTokens(t *testing.T, cfg config.JWTConfig) *utils.TokenService {
+	t.Helper()
+
+	tokens, err := utils.NewTokenService(cfg, nil)
+	if err != nil {
+		t.Fatalf("NewTokenService = %v", err)
+	}
+	return tokens
+}
+
+// writeKeys writes key as a PKCS8 private key and its public key as PKIX PEM
+// files, returning their paths
+func writeKeys(t *testing.T, key crypto.Signer) (privatePath, publicPath string) {
+	t.Helper()
+
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey = %v", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey = %v", err)
+	}
+
+	dir := t.TempDir()
+	privatePath = filepath.Join(dir, "private.pem")
+	publicPath = filepath.Join(dir, "public.pem")
+	if err := os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+// signRaw signs claims with an arbitrary method, key and kid
+func signRaw(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString = %v", err)
+	}
+	return signed
+}
+
+// validClaims returns claims the test services accept
+func validClaims() jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Subject:   "1",
+		Issuer:    "test",
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	}
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	tokens := newTokens(t, hsConfig())
+
+	token, err := tokens.Generate("42")
+	if err != nil {
+		t.Fatalf("Generate = %v", err)
+	}
+	claims, err := tokens.Parse(token)
+	if err != nil {
+		t.Fatalf("Parse = %v", err)
+	}
+	if claims.Subject != "42" || claims.Issuer != "test" || claims.ID == "" || claims.ExpiresAt == nil {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestParseIntoCustomClaims(t *testing.T) {
+	type appClaims struct {
+		utils.Claims
+		Plan string `json:"plan"`
+	}
+	tokens := newTokens(t, hsConfig())
+
+	token, err := tokens.Sign(&appClaims{Claims: utils.Claims{Roles: []string{"admin"}}, Plan: "pro"})
+	if err != nil {
+		t.Fatalf("Sign = %v", err)
+	}
+
+	var got appClaims
+	if err := tokens.ParseInto(token, &got); err != nil {
+		t.Fatalf("ParseInto = %v", err)
+	}
+	if got.Plan != "pro" || !reflect.DeepEqual(got.Roles, []string{"admin"}) {
+		t.Errorf("claims = %+v", got)
+	}
+}
+
+func TestParseRejectsInvalidTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath, publicPath := writeKeys(t, rsaKey)
+	publicPEM, err := os.ReadFile(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsCfg := hsConfig()
+	rsCfg.Algorithm = "RS256"
+	rsCfg.PrivateKeyFile = privatePath
+	rsCfg.KeyID = "current"
+	rsTokens := newTokens(t, rsCfg)
+	hsTokens := newTokens(t, hsConfig())
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "someone-else"
+	future := validClaims()
+	future.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		tokens *utils.TokenService
+		token  string
+	}{
+		{"garbage", hsTokens, "not.a.token"},
+		{"wrong secret", hsTokens, signRaw(t, jwt.SigningMethodHS256, []byte("another-secret-that-is-32-bytes-long"), "", validClaims())},
+		{"expired", hsTokens, signRaw(t, jwt.SigningMethodHS256, []byte(testSecret), "", expired)},
+		{"no expiry", hsTokens, signRaw(t, jwt.SigningMethodHS256, []byte(testSecret), "", noExpiry)},
+		{"wrong issuer", hsTokens, signRaw(t, jwt.SigningMethodHS256, []byte(testSecret), "", wrongIssuer)},
+		{"issued in the future", hsTokens, signRaw(t, jwt.SigningMethodHS256, []byte(testSecret), "", future)},
+		{"alg none", hsTokens, signRaw(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims())},
+		{"HS384 with the secret", hsTokens, signRaw(t, jwt.SigningMethodHS384, []byte(testSecret), "", validClaims())},
+		// Key confusion: an HMAC keyed with the public key must not verify
+		{"HS256 with the public key", rsTokens, signRaw(t, jwt.SigningMethodHS256, publicPEM, "current", validClaims())},
+		{"unknown kid", rsTokens, signRaw(t, jwt.SigningMethodRS256, rsaKey, "unknown", validClaims())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.tokens.Parse(tt.token); !errors.Is(err, utils.ErrInvalidToken) {
+				t.Errorf("Parse = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestAsymmetricAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		algorithm string
+		key       crypto.Signer
+		keyType   string
+	}{
+		{"RS256", rsaKey, "RSA"},
+		{"ES256", ecKey, "EC"},
+		{"EdDSA", edKey, "OKP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			privatePath, _ := writeKeys(t, tt.key)
+			cfg := hsConfig()
+			cfg.Algorithm = tt.algorithm
+			cfg.PrivateKeyFile = privatePath
+			cfg.KeyID = "k1"
+			tokens := newTokens(t, cfg)
+
+			token, err := tokens.Generate("1")
+			if err != nil {
+				t.Fatalf("Generate = %v", err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil || parsed.Method.Alg() != tt.algorithm || parsed.Header["kid"] != "k1" {
+				t.Fatalf("header = %v, %v", parsed.Header, err)
+			}
+			if _, err := tokens.Parse(token); err != nil {
+				t.Fatalf("Parse = %v", err)
+			}
+
+			jwks := tokens.JWKS()
+			if len(jwks.Keys) != 1 {
+				t.Fatalf("JWKS = %+v, want one key", jwks)
+			}
+			jwk := jwks.Keys[0]
+			if jwk.KeyID != "k1" || jwk.KeyType != tt.keyType || jwk.Algorithm != tt.algorithm || jwk.Use != "sig" {
+				t.Errorf("JWK = %+v", jwk)
+			}
+		})
+	}
+
+	// Keys must match the configured algorithm
+	privatePath, _ := writeKeys(t, ecKey)
+	cfg := hsConfig()
+	cfg.Algorithm = "RS256"
+	cfg.PrivateKeyFile = privatePath
+	if _, err := utils.NewTokenService(cfg, nil); err == nil {
+		t.Error("NewTokenService accepted an EC key for RS256")
+	}
+}
+
+func TestJWKSPublishesRSAKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath, _ := writeKeys(t, key)
+
+	cfg := hsConfig()
+	cfg.Algorithm = "RS256"
+	cfg.PrivateKeyFile = privatePath
+	jwk := newTokens(t, cfg).JWKS().Keys[0]
+
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil || new(big.Int).SetBytes(n).Cmp(key.N) != 0 {
+		t.Errorf("n does not encode the modulus: %v", err)
+	}
+	if jwk.E != "AQAB" {
+		t.Errorf("e = %q, want AQAB", jwk.E)
+	}
+}
+
+func TestJWKSOmitsSecrets(t *testing.T) {
+	if jwks := newTokens(t, hsConfig()).JWKS(); len(jwks.Keys) != 0 {
+		t.Errorf("JWKS = %+v, want no keys for HS256", jwks)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, newPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPrivatePath, oldPublicPath := writeKeys(t, oldKey)
+	newPrivatePath, _ := writeKeys(t, newPrivate)
+
+	oldCfg := hsConfig()
+	oldCfg.Algorithm = "ES256"
+	oldCfg.PrivateKeyFile = oldPrivatePath
+	oldCfg.KeyID = "old"
+	oldToken, err := newTokens(t, oldCfg).Generate("1")
+	if err != nil {
+		t.Fatalf("Generate = %v", err)
+	}
+
+	newCfg := hsConfig()
+	newCfg.Algorithm = "EdDSA"
+	newCfg.PrivateKeyFile = newPrivatePath
+	newCfg.KeyID = "new"
+	newCfg.VerificationKeys = []string{"old=" + oldPublicPath}
+	tokens := newTokens(t, newCfg)
+
+	if _, err := tokens.Parse(oldToken); err != nil {
+		t.Errorf("Parse of token signed with the previous key = %v", err)
+	}
+
+	var kids []string
+	for _, key := range tokens.JWKS().Keys {
+		kids = append(kids, key.KeyID+"/"+key.Algorithm)
+	}
+	if want := []string{"new/EdDSA", "old/ES256"}; !reflect.DeepEqual(kids, want) {
+		t.Errorf("JWKS keys = %v, want %v", kids, want)
+	}
+
+	// The old kid only verifies its own algorithm
+	forged := signRaw(t, jwt.SigningMethodEdDSA, newPrivate, "old", validClaims())
+	if _, err := tokens.Parse(forged); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Errorf("Parse of EdDSA token with the ES256 kid = %v, want ErrInvalidToken", err)
+	}
+
+	// A verification key may not shadow the signing key
+	newCfg.VerificationKeys = []string{"new=" + oldPublicPath}
+	if _, err := utils.NewTokenService(newCfg, nil); err == nil {
+		t.Error("NewTokenService accepted a verification key with the signing key ID")
+	}
+}
+
+func TestRejectsWeakRSAKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath, _ := writeKeys(t, key)
+
+	cfg := hsConfig()
+	cfg.Algorithm = "RS256"
+	cfg.PrivateKeyFile = privatePath
+	if _, err := utils.NewTokenService(cfg, nil); err == nil {
+		t.Error("NewTokenService accepted a 1024-bit RSA key")
+	}
+}
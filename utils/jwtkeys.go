@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"example.com/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// verificationKey is a key accepted for tokens signed with algorithm
+type verificationKey struct {
+	algorithm string
+	key       interface{}
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that verify tokens, so other services can
+// check them without sharing a secret. HS256 secrets are never published.
+func (s *TokenService) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for kid, key := range s.keys {
+		// The "" entry aliases a named key unless no key ID is configured
+		if kid == "" && s.keyID != "" {
+			continue
+		}
+
+		jwk := JWK{KeyID: kid, Use: "sig", Algorithm: key.algorithm}
+		switch public := key.key.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = encodeBase64URL(public.N.Bytes())
+			jwk.E = encodeBase64URL(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk.KeyType = "EC"
+			jwk.Curve = public.Curve.Params().Name
+			jwk.X = encodeBase64URL(public.X.FillBytes(make([]byte, size)))
+			jwk.Y = encodeBase64URL(public.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = encodeBase64URL(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// loadSigningKey returns the signing method and key for the configured
+// algorithm, along with the key that verifies its signatures
+func loadSigningKey(cfg config.JWTConfig) (jwt.SigningMethod, interface{}, verificationKey, error) {
+	if cfg.Algorithm == "" || cfg.Algorithm == "HS256" {
+		if cfg.Secret == "" {
+			return nil, nil, verificationKey{}, fmt.Errorf("JWT secret must not be empty")
+		}
+		secret := []byte(cfg.Secret)
+		return jwt.SigningMethodHS256, secret, verificationKey{algorithm: "HS256", key: secret}, nil
+	}
+
+	block, err := readPEM(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, nil, verificationKey{}, err
+	}
+
+	var private crypto.Signer
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, verificationKey{}, fmt.Errorf("failed to parse JWT private key: %w", err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, nil, verificationKey{}, fmt.Errorf("unsupported JWT private key type %T", key)
+		}
+		private = signer
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, nil, verificationKey{}, fmt.Errorf("unsupported PEM block %q in JWT private key", block.Type)
+	}
+	if err != nil {
+		return nil, nil, verificationKey{}, fmt.Errorf("failed to parse JWT private key: %w", err)
+	}
+
+	algorithm, err := keyAlgorithm(private.Public())
+	if err != nil {
+		return nil, nil, verificationKey{}, err
+	}
+	if algorithm != cfg.Algorithm {
+		return nil, nil, verificationKey{}, fmt.Errorf("JWT private key is for %s, not %s", algorithm, cfg.Algorithm)
+	}
+
+	return jwt.GetSigningMethod(algorithm), private, verificationKey{algorithm: algorithm, key: private.Public()}, nil
+}
+
+// loadVerificationKeys reads PEM public keys or certificates given as kid=path
+func loadVerificationKeys(entries []string) (map[string]verificationKey, error) {
+	keys := make(map[string]verificationKey, len(entries)+2)
+
+	for _, entry := range entries {
+		kid, path, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("invalid JWT verification key %q, expected kid=path", entry)
+		}
+		if _, exists := keys[kid]; exists {
+			return nil, fmt.Errorf("duplicate JWT verification key ID %q", kid)
+		}
+
+		public, err := loadPublicKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("JWT verification key %q: %w", kid, err)
+		}
+		algorithm, err := keyAlgorithm(public)
+		if err != nil {
+			return nil, fmt.Errorf("JWT verification key %q: %w", kid, err)
+		}
+		keys[kid] = verificationKey{algorithm: algorithm, key: public}
+	}
+
+	return keys, nil
+}
+
+// loadPublicKey reads a PEM public key or certificate
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// keyAlgorithm returns the JWT algorithm used with a public key
+func keyAlgorithm(public crypto.PublicKey) (string, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return "", fmt.Errorf("RSA keys must be at least 2048 bits")
+		}
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("ES256 requires a P-256 key, got %s", key.Curve.Params().Name)
+		}
+		return "ES256", nil
+	case ed25519.PublicKey:
+		return "EdDSA", nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", public)
+	}
+}
+
+// readPEM reads the first PEM block of a file
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return block, nil
+}
+
+// encodeBase64URL encodes bytes as unpadded base64url, as JWKs require
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"example.com/models"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+	// ErrRefreshTokenReused is returned when an already rotated refresh token
+	// is presented again; its whole family is revoked
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
+	// ErrRefreshTokenNotFound is returned by a RefreshTokenStore for unknown hashes
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+)
+
+// RefreshTokenStore persists hashed refresh tokens
+type RefreshTokenStore interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	FindByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	MarkUsed(ctx context.Context, id uint, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	RevokeSubject(ctx context.Context, subject string, at time.Time) error
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ClaimsLoader returns the current claims of a subject, such as its roles,
+// so that tokens issued on refresh reflect changes since login. It should
+// wrap ErrInvalidRefreshToken if the subject no longer exists.
+type ClaimsLoader func(ctx context.Context, subject string) (*Claims, error)
+
+// SetClaimsLoader sets the loader for the claims of issued token pairs.
+// Without one, pairs only carry the subject. Call it before issuing tokens.
+func (s *TokenService) SetClaimsLoader(loader ClaimsLoader) {
+	s.loader = loader
+}
+
+// TokenPair is a short-lived access token and the refresh token that renews it
+type TokenPair struct {
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+// IssuePair starts a new refresh token family for subject, e.g. at login
+func (s *TokenService) IssuePair(ctx context.Context, subject string) (*TokenPair, error) {
+	familyID, err := randomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+	return s.issuePair(ctx, subject, hex.EncodeToString(familyID))
+}
+
+// Refresh exchanges a refresh token for a new pair in the same family. Every
+// refresh token can be used once; presenting it again means it was stolen
+// or replayed, so the whole family is revoked and ErrRefreshTokenReused returned.
+func (s *TokenService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	token, err := s.findRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if token.RevokedAt != nil || now.After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	marked := false
+	if token.UsedAt == nil {
+		if marked, err = s.store.MarkUsed(ctx, token.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// Either used before, or a concurrent refresh won the race
+	if !marked {
+		if err := s.store.RevokeFamily(ctx, token.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	return s.issuePair(ctx, token.Subject, token.FamilyID)
+}
+
+// Revoke revokes the family of a refresh token, e.g. at logout
+func (s *TokenService) Revoke(ctx context.Context, refreshToken string) error {
+	token, err := s.findRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	return s.store.RevokeFamily(ctx, token.FamilyID, time.Now().UTC())
+}
+
+// RevokeAll revokes every refresh token of subject, signing it out of all
+// sessions once their access tokens expire, e.g. after a password change
+func (s *TokenService) RevokeAll(ctx context.Context, subject string) error {
+	if s.store == nil {
+		return fmt.Errorf("refresh tokens require a token store")
+	}
+	return s.store.RevokeSubject(ctx, subject, time.Now().UTC())
+}
+
+// CleanupExpired deletes expired refresh tokens every interval until ctx is
+// done. A non-positive interval disables cleanup.
+func (s *TokenService) CleanupExpired(ctx context.Context, interval time.Duration, log *Logger) {
+	if interval <= 0 || s.store == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := s.store.DeleteExpired(ctx, time.Now().UTC())
+		if err != nil {
+			log.Warn("Failed to delete expired refresh tokens", map[string]interface{}{
+				"error": err.Error(),
+			})
+			continue
+		}
+		if deleted > 0 {
+			log.Info("Deleted expired refresh tokens", map[string]interface{}{
+				"deleted": deleted,
+			})
+		}
+	}
+}
+
+// issuePair signs an access token and stores a new refresh token in the family
+func (s *TokenService) issuePair(ctx context.Context, subject, familyID string) (*TokenPair, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("refresh tokens require a token store")
+	}
+
+	claims := &Claims{}
+	if s.loader != nil {
+		loaded, err := s.loader(ctx, subject)
+		if err != nil {
+			return nil, err
+		}
+		claims = loaded
+	}
+	claims.Subject = subject
+
+	accessToken, err := s.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshHash, err := NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	record := &models.RefreshToken{
+		TokenHash: refreshHash,
+		FamilyID:  familyID,
+		Subject:   subject,
+		ExpiresAt: now.Add(s.refreshTTL),
+	}
+	if err := s.store.Create(ctx, record); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  now.Add(s.ttl),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: record.ExpiresAt,
+	}, nil
+}
+
+// findRefreshToken looks up a refresh token by its hash
+func (s *TokenService) findRefreshToken(ctx context.Context, refreshToken string) (*models.RefreshToken, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("refresh tokens require a token store")
+	}
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	token, err := s.store.FindByHash(ctx, HashToken(refreshToken))
+	if errors.Is(err, ErrRefreshTokenNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	return token, err
+}
+
+// NewOpaqueToken returns a random URL-safe token and its hash for storage
+func NewOpaqueToken() (token, hash string, err error) {
+	secret, err := randomBytes(32)
+	if err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(secret)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex SHA-256 of a token from NewOpaqueToken. The
+// tokens are random and long, so an unsalted fast hash is sufficient.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomBytes returns n bytes from the system CSPRNG
+func randomBytes(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return buf, nil
+}
@@ -0,0 +1,227 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/config"
+	"example.com/database"
+	"example.com/database/dbtest"
+	"example.com/migrations"
+	"example.com/utils"
+)
+
+// newRefreshTokens returns a TokenService backed by a migrated test database
+func newRefreshTokens(t *testing.T, refreshTTL time.Duration) *utils.TokenService {
+	t.Helper()
+
+	all, err := migrations.All()
+	if err != nil {
+		t.Fatalf("migrations.All = %v", err)
+	}
+	db := dbtest.New(t, dbtest.Options{Migrations: all})
+
+	tokens, err := utils.NewTokenService(config.JWTConfig{
+		Secret:          "test-secret-that-is-at-least-32-bytes",
+		Issuer:          "test",
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: refreshTTL,
+		Algorithm:       "HS256",
+	}, database.NewRefreshTokenStore(db))
+	if err != nil {
+		t.Fatalf("NewTokenService = %v", err)
+	}
+	return tokens
+}
+
+func TestRefreshRotates(t *testing.T) {
+	ctx := context.Background()
+	tokens := newRefreshTokens(t, time.Hour)
+
+	pair, err := tokens.IssuePair(ctx, "1")
+	if err != nil {
+		t.Fatalf("IssuePair = %v", err)
+	}
+	claims, err := tokens.Parse(pair.AccessToken)
+	if err != nil || claims.Subject != "1" {
+		t.Fatalf("Parse(access token) = %+v, %v", claims, err)
+	}
+
+	next, err := tokens.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh = %v", err)
+	}
+	if next.RefreshToken == pair.RefreshToken || next.AccessToken == pair.AccessToken {
+		t.Fatal("Refresh did not rotate the tokens")
+	}
+
+	if _, err := tokens.Refresh(ctx, next.RefreshToken); err != nil {
+		t.Fatalf("Refresh with rotated token = %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	tokens := newRefreshTokens(t, time.Hour)
+
+	stolen, err := tokens.IssuePair(ctx, "1")
+	if err != nil {
+		t.Fatalf("IssuePair = %v", err)
+	}
+	other, err := tokens.IssuePair(ctx, "1")
+	if err != nil {
+		t.Fatalf("IssuePair = %v", err)
+	}
+
+	next, err := tokens.Refresh(ctx, stolen.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh = %v", err)
+	}
+
+	if _, err := tokens.Refresh(ctx, stolen.RefreshToken); !errors.Is(err, utils.ErrRefreshTokenReused) {
+		t.Fatalf("second Refresh = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := tokens.Refresh(ctx, next.RefreshToken); !errors.Is(err, utils.ErrInvalidRefreshToken) {
+		t.Errorf("Refresh in revoked family = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Other sessions of the subject are separate families
+	if _, err := tokens.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("Refresh in other family = %v", err)
+	}
+}
+
+func TestRefreshConcurrentUseWinsOnce(t *testing.T) {
+	ctx := context.Background()
+	tokens := newRefreshTokens(t, time.Hour)
+
+	pair, err := tokens.IssuePair(ctx, "1")
+	if err != nil {
+		t.Fatalf("IssuePair = %v", err)
+	}
+
+	const attempts = 8
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := tokens.Refresh(ctx, pair.RefreshToken)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// Losers either detect the reuse or find the family already revoked
+	succeeded, reused := 0, 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, utils.ErrRefreshTokenReused):
+			reused++
+		case !errors.Is(err, utils.ErrInvalidRefreshToken):
+			t.Errorf("Refresh = %v", err)
+		}
+	}
+	if succeeded != 1 || reused == 0 {
+		t.Errorf("%d refreshes succeeded and %d detected reuse, want 1 and at least 1", succeeded, reused)
+	}
+}
+
+func TestRefreshRejectsInvalidTokens(t *testing.T) {
+	ctx := context.Background()
+	tokens := newRefreshTokens(t, 10*time.Millisecond)
+
+	pair, err := tokens.IssuePair(ctx, "1")
+	if err != nil {
+		t.Fatalf("IssuePair = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	tests := map[string]string{
+		"empty":   "",
+		"unknown": "not-a-refresh-token",
+		"expired": pair.RefreshToken,
+		"access":  pair.AccessToken,
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := tokens.Refresh(ctx, token); !errors.Is(err, utils.ErrInvalidRefreshToken) {
+				t.Errorf("Refresh = %v, want ErrInvalidRefreshToken", err)
+			}
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	tokens := newRefreshTokens(t, time.Hour)
+
+	issue := func(subject string) *utils.TokenPair {
+		pair, err := tokens.IssuePair(ctx, subject)
+		if err != nil {
+			t.Fatalf("IssuePair = %v", err)
+		}
+		return pair
+	}
+	loggedOut, first, second, otherUser := issue("1"), issue("1"), issue("1"), issue("2")
+
+	if err := tokens.Revoke(ctx, loggedOut.RefreshToken); err != nil {
+		t.Fatalf("Revoke = %v", err)
+	}
+	if _, err := tokens.Refresh(ctx, loggedOut.RefreshToken); !errors.Is(err, utils.ErrInvalidRefreshToken) {
+		t.Errorf("Refresh after Revoke = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	if err := tokens.RevokeAll(ctx, "1"); err != nil {
+		t.Fatalf("RevokeAll = %v", err)
+	}
+	for _, pair := range []*utils.TokenPair{first, second} {
+		if _, err := tokens.Refresh(ctx, pair.RefreshToken); !errors.Is(err, utils.ErrInvalidRefreshToken) {
+			t.Errorf("Refresh after RevokeAll = %v, want ErrInvalidRefreshToken", err)
+		}
+	}
+	if _, err := tokens.Refresh(ctx, otherUser.RefreshToken); err != nil {
+		t.Errorf("Refresh of another subject after RevokeAll = %v", err)
+	}
+}
+
+func TestRefreshLoadsClaims(t *testing.T) {
+	ctx := context.Background()
+	tokens := newRefreshTokens(t, time.Hour)
+
+	roles := []string{"viewer"}
+	tokens.SetClaimsLoader(func(ctx context.Context, subject string) (*utils.Claims, error) {
+		if subject == "gone" {
+			return nil, utils.ErrInvalidRefreshToken
+		}
+		return &utils.Claims{Roles: roles}, nil
+	})
+
+	pair, err := tokens.IssuePair(ctx, "1")
+	if err != nil {
+		t.Fatalf("IssuePair = %v", err)
+	}
+
+	// Role changes apply from the next refresh
+	roles = []string{"admin"}
+	next, err := tokens.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh = %v", err)
+	}
+	claims, err := tokens.Parse(next.AccessToken)
+	if err != nil || claims.Subject != "1" || !reflect.DeepEqual(claims.Roles, []string{"admin"}) {
+		t.Fatalf("refreshed claims = %+v, %v", claims, err)
+	}
+
+	if _, err := tokens.IssuePair(ctx, "gone"); !errors.Is(err, utils.ErrInvalidRefreshToken) {
+		t.Errorf("IssuePair for removed subject = %v, want ErrInvalidRefreshToken", err)
+	}
+}
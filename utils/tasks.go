@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// Tasks tracks work that outlives the request starting it, so shutdown can
+// wait for it before closing what it uses
+type Tasks struct {
+	wg sync.WaitGroup
+}
+
+// NewTasks creates an empty Tasks
+func NewTasks() *Tasks {
+	return &Tasks{}
+}
+
+// Go runs fn in a new goroutine
+func (t *Tasks) Go(fn func()) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until every fn started with Go has returned, or ctx is done.
+// Tasks started by running tasks are waited for too.
+func (t *Tasks) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
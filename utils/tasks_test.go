@@ -0,0 +1,46 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/utils"
+)
+
+func TestTasksWaitsForNestedTasks(t *testing.T) {
+	tasks := utils.NewTasks()
+
+	var finished atomic.Int32
+	tasks.Go(func() {
+		time.Sleep(10 * time.Millisecond)
+		tasks.Go(func() {
+			time.Sleep(10 * time.Millisecond)
+			finished.Add(1)
+		})
+		finished.Add(1)
+	})
+
+	if err := tasks.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait = %v", err)
+	}
+	if n := finished.Load(); n != 2 {
+		t.Errorf("%d tasks finished before Wait returned, want 2", n)
+	}
+}
+
+func TestTasksWaitGivesUp(t *testing.T) {
+	tasks := utils.NewTasks()
+
+	release := make(chan struct{})
+	defer close(release)
+	tasks.Go(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tasks.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want DeadlineExceeded", err)
+	}
+}